@@ -0,0 +1,229 @@
+// Package aws shows the AWS CLI profile and region, reading ~/.aws/config,
+// ~/.aws/credentials and the SSO token cache directly, like gcp does for
+// gcloud.
+package aws
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hergert/ccsl/internal/palette"
+	"github.com/hergert/ccsl/internal/types"
+)
+
+type Status struct {
+	Profile  string // "env" for credentials from the environment alone
+	Region   string
+	Mismatch bool   // AWS_ACCESS_KEY_ID isn't the profile's key
+	Expired  bool   // the profile's SSO token has run out
+	URL      string // the SSO portal, else the console in Region
+}
+
+// Collect resolves the profile the AWS CLI would use. It is false when
+// nothing points at AWS: no profile in the environment or the files, and
+// no access key.
+func Collect(now time.Time) (Status, bool) {
+	home, _ := os.UserHomeDir()
+	configFile := envOr("AWS_CONFIG_FILE", filepath.Join(home, ".aws", "config"))
+	credsFile := envOr("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(home, ".aws", "credentials"))
+	config := parseINI(configFile)
+	creds := parseINI(credsFile)
+
+	name := os.Getenv("AWS_PROFILE")
+	if name == "" {
+		name = os.Getenv("AWS_DEFAULT_PROFILE")
+	}
+	selected := name != ""
+	if name == "" {
+		name = "default"
+	}
+	profile, known := lookupProfile(config, creds, name)
+	envKey := os.Getenv("AWS_ACCESS_KEY_ID")
+
+	// Keys in the environment win over [default]; only a profile named
+	// in AWS_PROFILE is used alongside them.
+	var s Status
+	switch {
+	case selected:
+		s.Profile = name
+	case envKey != "":
+		s.Profile = "env"
+	case known:
+		s.Profile = name
+	default:
+		return Status{}, false
+	}
+
+	s.Region = os.Getenv("AWS_REGION")
+	if s.Region == "" {
+		s.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if s.Region == "" {
+		s.Region = profile["region"]
+	}
+
+	var startURL string
+	if s.Profile != "env" {
+		if envKey != "" {
+			s.Mismatch = envKey != profile["aws_access_key_id"] && os.Getenv("AWS_VAULT") != name
+		}
+		var tokenFile string
+		startURL, tokenFile = ssoToken(config, creds, name, home)
+		if tokenFile != "" {
+			s.Expired = tokenExpired(tokenFile, now)
+		}
+	}
+	switch {
+	case startURL != "":
+		s.URL = startURL
+	case s.Region != "":
+		s.URL = "https://console.aws.amazon.com/console/home?region=" + url.QueryEscape(s.Region)
+	default:
+		s.URL = "https://console.aws.amazon.com/"
+	}
+	return s, true
+}
+
+// Render shows aws:profile@region, ⚠ on a mismatched access key and a dim
+// ⌛ once the SSO login has expired; the short form leaves the region out.
+func (s Status) Render(pal *palette.Palette) types.Segment {
+	text := "aws:" + s.Profile
+	short := text
+	if s.Region != "" {
+		text += "@" + s.Region
+	}
+	if s.Mismatch {
+		text += "⚠"
+		short += "⚠"
+	}
+	if s.Expired {
+		text += pal.Apply("⌛", "dim")
+		short += pal.Apply("⌛", "dim")
+	}
+	return types.Segment{
+		Text:     text,
+		Short:    short,
+		Link:     s.URL,
+		Style:    "dim",
+		Priority: 35,
+	}
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// lookupProfile merges a profile's settings from credentials and config
+// (where it is [profile name], or [default]), credentials first.
+func lookupProfile(config, creds map[string]map[string]string, name string) (map[string]string, bool) {
+	section := "profile " + name
+	if name == "default" {
+		if _, ok := config[section]; !ok {
+			section = "default"
+		}
+	}
+	merged := make(map[string]string)
+	c, inConfig := config[section]
+	for k, v := range c {
+		merged[k] = v
+	}
+	cr, inCreds := creds[name]
+	for k, v := range cr {
+		merged[k] = v
+	}
+	return merged, inConfig || inCreds
+}
+
+// ssoToken finds the SSO login behind profile name, following role_arn
+// profiles through source_profile: the portal's start URL and the token
+// cache file, named after the sha1 of the sso-session name, or of the
+// start URL for profiles configured the legacy way.
+func ssoToken(config, creds map[string]map[string]string, name, home string) (startURL, file string) {
+	for hops := 0; hops < 5 && name != ""; hops++ {
+		p, ok := lookupProfile(config, creds, name)
+		if !ok {
+			return "", ""
+		}
+		var key string
+		switch {
+		case p["sso_session"] != "":
+			key = p["sso_session"]
+			startURL = config["sso-session "+key]["sso_start_url"]
+		case p["sso_start_url"] != "":
+			key, startURL = p["sso_start_url"], p["sso_start_url"]
+		default:
+			name = p["source_profile"]
+			continue
+		}
+		sum := sha1.Sum([]byte(key))
+		return startURL, filepath.Join(home, ".aws", "sso", "cache", hex.EncodeToString(sum[:])+".json")
+	}
+	return "", ""
+}
+
+// tokenExpired reads expiresAt from a cached SSO token. A missing token
+// counts as expired: the user still has to log in.
+func tokenExpired(path string, now time.Time) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return true
+	}
+	var token struct {
+		ExpiresAt string `json:"expiresAt"`
+	}
+	if json.Unmarshal(data, &token) != nil {
+		return false
+	}
+	// The CLI writes RFC 3339; older versions "2024-01-02T15:04:05UTC".
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05UTC"} {
+		if t, err := time.Parse(layout, token.ExpiresAt); err == nil {
+			return !now.Before(t)
+		}
+	}
+	return false
+}
+
+// parseINI reads an AWS config or credentials file into its sections.
+// Indented lines belong to a nested setting (s3 = ...) and are skipped.
+func parseINI(path string) map[string]map[string]string {
+	sections := make(map[string]map[string]string)
+	file, err := os.Open(path)
+	if err != nil {
+		return sections
+	}
+	defer func() { _ = file.Close() }()
+
+	var current map[string]string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+			continue
+		case line[0] == '[' && strings.HasSuffix(line, "]"):
+			name := strings.Join(strings.Fields(line[1:len(line)-1]), " ")
+			if sections[name] == nil {
+				sections[name] = make(map[string]string)
+			}
+			current = sections[name]
+			continue
+		case current == nil || raw[0] == ' ' || raw[0] == '\t':
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			current[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return sections
+}
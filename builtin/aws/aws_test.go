@@ -0,0 +1,167 @@
+package aws
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const awsConfig = `[default]
+region = us-east-1
+
+[profile dev]
+region = eu-west-1
+output = json
+s3 =
+  max_concurrent_requests = 20
+
+[profile sso]
+sso_session = corp
+sso_account_id = 123456789012
+region = eu-central-1
+
+[sso-session corp]
+sso_start_url = https://corp.awsapps.com/start
+sso_region = eu-central-1
+
+[profile admin]
+role_arn = arn:aws:iam::123456789012:role/Admin
+source_profile = sso
+
+[profile legacy]
+sso_start_url = https://old.awsapps.com/start
+`
+
+const awsCredentials = `[dev]
+aws_access_key_id = AKIADEV
+aws_secret_access_key = secret
+
+[ci]
+aws_access_key_id = AKIACI
+`
+
+var now = time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// setup writes the files under a fresh HOME and clears the AWS environment.
+func setup(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, k := range []string{"AWS_PROFILE", "AWS_DEFAULT_PROFILE", "AWS_REGION", "AWS_DEFAULT_REGION",
+		"AWS_ACCESS_KEY_ID", "AWS_VAULT", "AWS_CONFIG_FILE", "AWS_SHARED_CREDENTIALS_FILE"} {
+		t.Setenv(k, "")
+	}
+	dir := filepath.Join(home, ".aws")
+	if err := os.MkdirAll(filepath.Join(dir, "sso", "cache"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	for name, body := range map[string]string{"config": awsConfig, "credentials": awsCredentials} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return home
+}
+
+func cacheToken(t *testing.T, home, key, expiresAt string) {
+	t.Helper()
+	sum := sha1.Sum([]byte(key))
+	path := filepath.Join(home, ".aws", "sso", "cache", hex.EncodeToString(sum[:])+".json")
+	if err := os.WriteFile(path, []byte(`{"accessToken":"x","expiresAt":"`+expiresAt+`"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	cases := []struct {
+		name string
+		env  map[string]string
+		text string
+	}{
+		{"default profile", nil, "aws:default@us-east-1"},
+		{"AWS_PROFILE", map[string]string{"AWS_PROFILE": "dev"}, "aws:dev@eu-west-1"},
+		{"AWS_DEFAULT_PROFILE", map[string]string{"AWS_DEFAULT_PROFILE": "dev"}, "aws:dev@eu-west-1"},
+		{"AWS_REGION wins", map[string]string{"AWS_PROFILE": "dev", "AWS_REGION": "ap-south-1", "AWS_DEFAULT_REGION": "sa-east-1"}, "aws:dev@ap-south-1"},
+		{"credentials-only profile", map[string]string{"AWS_PROFILE": "ci"}, "aws:ci"},
+		{"profile's own key", map[string]string{"AWS_PROFILE": "dev", "AWS_ACCESS_KEY_ID": "AKIADEV"}, "aws:dev@eu-west-1"},
+		{"someone else's key", map[string]string{"AWS_PROFILE": "dev", "AWS_ACCESS_KEY_ID": "AKIAOTHER"}, "aws:dev@eu-west-1⚠"},
+		{"key over an sso profile", map[string]string{"AWS_PROFILE": "sso", "AWS_ACCESS_KEY_ID": "ASIATEMP"}, "aws:sso@eu-central-1⚠"},
+		{"exported key, no profile", map[string]string{"AWS_ACCESS_KEY_ID": "AKIAOTHER"}, "aws:env@us-east-1"},
+		{"aws-vault exported", map[string]string{"AWS_PROFILE": "sso", "AWS_VAULT": "sso", "AWS_ACCESS_KEY_ID": "ASIATEMP"}, "aws:sso@eu-central-1"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			home := setup(t)
+			cacheToken(t, home, "corp", "2026-05-01T20:00:00Z")
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			s, ok := Collect(now)
+			if seg := s.Render(nil); !ok || seg.Text != tc.text {
+				t.Errorf("Render = %q (%v), want %q", seg.Text, ok, tc.text)
+			}
+		})
+	}
+}
+
+func TestCollectEnvOnly(t *testing.T) {
+	home := setup(t)
+	if err := os.RemoveAll(filepath.Join(home, ".aws")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Collect(now); ok {
+		t.Error("Collect with no AWS setup is ok")
+	}
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAENV")
+	t.Setenv("AWS_REGION", "us-west-2")
+	if s, _ := Collect(now); s.Render(nil).Text != "aws:env@us-west-2" {
+		t.Errorf("Render = %q", s.Render(nil).Text)
+	}
+}
+
+func TestSSOExpiry(t *testing.T) {
+	cases := []struct {
+		name, profile, key, expiresAt string
+		expired                       bool
+	}{
+		{"valid session token", "sso", "corp", "2026-05-01T20:00:00Z", false},
+		{"expired session token", "sso", "corp", "2026-05-01T11:00:00Z", true},
+		{"role through sso", "admin", "corp", "2026-05-01T11:00:00Z", true},
+		{"legacy UTC format", "legacy", "https://old.awsapps.com/start", "2026-05-01T13:00:00UTC", false},
+		{"never logged in", "sso", "other", "2026-05-01T20:00:00Z", true},
+		{"static keys", "dev", "corp", "2026-05-01T11:00:00Z", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			home := setup(t)
+			t.Setenv("AWS_PROFILE", tc.profile)
+			cacheToken(t, home, tc.key, tc.expiresAt)
+			s, _ := Collect(now)
+			if s.Expired != tc.expired {
+				t.Errorf("Expired = %v, want %v", s.Expired, tc.expired)
+			}
+		})
+	}
+}
+
+func TestRenderLink(t *testing.T) {
+	home := setup(t)
+	cacheToken(t, home, "corp", "2026-05-01T11:00:00Z")
+	t.Setenv("AWS_PROFILE", "sso")
+	s, _ := Collect(now)
+	seg := s.Render(nil)
+	if seg.Link != "https://corp.awsapps.com/start" {
+		t.Errorf("Link = %q", seg.Link)
+	}
+	if seg.Text != "aws:sso@eu-central-1⌛" || seg.Short != "aws:sso⌛" {
+		t.Errorf("Render = %q/%q", seg.Text, seg.Short)
+	}
+
+	t.Setenv("AWS_PROFILE", "dev")
+	if s, _ := Collect(now); s.URL != "https://console.aws.amazon.com/console/home?region=eu-west-1" {
+		t.Errorf("URL = %q", s.URL)
+	}
+}
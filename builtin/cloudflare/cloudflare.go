@@ -2,6 +2,7 @@ package cloudflare
 
 import (
 	"encoding/json"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -65,8 +66,19 @@ func Render(raw map[string]any) types.Segment {
 		text += "⚠"
 	}
 
+	// The worker in the account wrangler would deploy to.
+	link := "https://dash.cloudflare.com/"
+	account := envAccountID
+	if account == "" {
+		account = cfg.AccountID
+	}
+	if account != "" {
+		link += url.PathEscape(account) + "/workers/services/view/" + url.PathEscape(projectName)
+	}
+
 	return types.Segment{
 		Text:     text,
+		Link:     link,
 		Style:    "dim",
 		Priority: 35,
 	}
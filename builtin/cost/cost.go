@@ -5,12 +5,47 @@ import (
 	"strings"
 	"time"
 
+	"github.com/hergert/ccsl/internal/config"
 	"github.com/hergert/ccsl/internal/types"
 )
 
 type Session struct {
-	CostUSD  float64
-	Duration time.Duration
+	CostUSD   float64
+	Estimated bool // CostUSD was worked out from token counts, shown as ~$
+	Duration  time.Duration
+	Budget    string // "", "yellow" or "red" from CheckBudget
+}
+
+// Spent is what counts against the [budget] limits besides the session.
+type Spent struct {
+	Day     float64
+	Month   float64
+	Project float64 // this project, today
+}
+
+// CheckBudget returns "red" when any limit is exceeded, "yellow" when any
+// reaches the warn_at fraction, else "".
+func (s Session) CheckBudget(b config.BudgetConfig, spent Spent) string {
+	warnAt := b.WarnAt
+	if warnAt <= 0 || warnAt > 1 {
+		warnAt = 0.8
+	}
+	level := ""
+	for _, c := range []struct{ spent, limit float64 }{
+		{s.CostUSD, b.SessionUSD},
+		{spent.Day, b.DailyUSD},
+		{spent.Month, b.MonthlyUSD},
+		{spent.Project, b.ProjectUSD},
+	} {
+		switch {
+		case c.limit <= 0:
+		case c.spent > c.limit:
+			return "red"
+		case c.spent >= c.limit*warnAt:
+			level = "yellow"
+		}
+	}
+	return level
 }
 
 func Parse(raw map[string]any) (Session, bool) {
@@ -31,18 +66,34 @@ func Parse(raw map[string]any) (Session, bool) {
 	return s, true
 }
 
+// Render shows the cost with the session's duration in superscript, an
+// estimate as ~$0.42; the short form leaves the duration out.
 func (s Session) Render() types.Segment {
-	var text string
+	var money, text string
 	if s.CostUSD > 0 {
-		text = fmt.Sprintf("$%.2f", s.CostUSD)
+		money = fmt.Sprintf("$%.2f", s.CostUSD)
+		if s.Estimated {
+			money = "~" + money
+		}
 	}
+	text = money
 	if s.Duration > 0 {
 		text += s.formatDuration()
 	}
 
+	style := "dim"
+	if s.Budget != "" {
+		style = s.Budget
+	}
+	if s.Budget == "red" {
+		text += "!"
+		money += "!"
+	}
+
 	return types.Segment{
 		Text:     text,
-		Style:    "dim",
+		Short:    money,
+		Style:    style,
 		Priority: 40,
 	}
 }
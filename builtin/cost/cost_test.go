@@ -0,0 +1,67 @@
+package cost
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hergert/ccsl/internal/config"
+)
+
+func TestCheckBudget(t *testing.T) {
+	budget := config.BudgetConfig{SessionUSD: 5, DailyUSD: 20, MonthlyUSD: 300, ProjectUSD: 10, WarnAt: 0.8}
+	cases := []struct {
+		name    string
+		session float64
+		spent   Spent
+		want    string
+	}{
+		{"well under", 1, Spent{Day: 5, Month: 50, Project: 2}, ""},
+		{"session warn", 4, Spent{Day: 5, Month: 50, Project: 4}, "yellow"},
+		{"session over", 5.01, Spent{Day: 6, Month: 50, Project: 6}, "red"},
+		{"day warn", 1, Spent{Day: 16, Month: 50, Project: 2}, "yellow"},
+		{"month over beats day warn", 1, Spent{Day: 16, Month: 301, Project: 2}, "red"},
+		{"project over", 1, Spent{Day: 12, Month: 50, Project: 10.5}, "red"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := Session{CostUSD: tc.session}
+			if got := s.CheckBudget(budget, tc.spent); got != tc.want {
+				t.Errorf("CheckBudget = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckBudgetIgnoresUnsetLimits(t *testing.T) {
+	s := Session{CostUSD: 1000}
+	if got := s.CheckBudget(config.BudgetConfig{DailyUSD: 50}, Spent{Day: 1}); got != "" {
+		t.Errorf("CheckBudget = %q, want no warning without a session limit", got)
+	}
+}
+
+func TestRenderBudgetStyle(t *testing.T) {
+	cases := []struct {
+		budget    string
+		wantStyle string
+		wantText  string
+		wantShort string
+	}{
+		{"", "dim", "$1.23²ᵐ", "$1.23"},
+		{"yellow", "yellow", "$1.23²ᵐ", "$1.23"},
+		{"red", "red", "$1.23²ᵐ!", "$1.23!"},
+	}
+	for _, tc := range cases {
+		seg := Session{CostUSD: 1.23, Duration: 2 * time.Minute, Budget: tc.budget}.Render()
+		if seg.Style != tc.wantStyle || seg.Text != tc.wantText || seg.Short != tc.wantShort {
+			t.Errorf("budget %q: Render = %q/%q/%q, want %q/%q/%q", tc.budget,
+				seg.Text, seg.Short, seg.Style, tc.wantText, tc.wantShort, tc.wantStyle)
+		}
+	}
+}
+
+func TestRenderEstimate(t *testing.T) {
+	seg := Session{CostUSD: 0.42, Estimated: true, Duration: 2 * time.Minute}.Render()
+	if seg.Text != "~$0.42²ᵐ" || seg.Short != "~$0.42" {
+		t.Errorf("Render = %q/%q, want ~$0.42²ᵐ/~$0.42", seg.Text, seg.Short)
+	}
+}
@@ -3,6 +3,7 @@ package ctx
 import (
 	"fmt"
 
+	"github.com/hergert/ccsl/internal/config"
 	"github.com/hergert/ccsl/internal/types"
 )
 
@@ -10,6 +11,11 @@ type ContextWindow struct {
 	UsedPct     float64
 	WindowSize  float64
 	Exceeds200k bool
+	Levels      config.Levels // zero: defaults for the window size; see Configure
+
+	// Set by Track when [plugin.ctx] trend is on.
+	Delta     float64 // growth over the last turn, in percentage points
+	TurnsLeft int     // estimated turns until the red threshold
 }
 
 func Parse(raw map[string]any) (ContextWindow, bool) {
@@ -42,45 +48,48 @@ func (c ContextWindow) IsLarge() bool {
 	return c.WindowSize >= 500000
 }
 
-// Anthropic's MRCR v2 benchmark: Opus drops from 93% (256K) to 76% (1M).
-// User reports (github.com/anthropics/claude-code/issues/35296, #34685):
-//
-//	20-40% of 1M: degradation starts, wrong approaches
-//	40-60%: fabrications, confident false conclusions
-//	60%+:   prior facts inaccessible, irrecoverable loops
-//
-// Effective useful context is ~200-400K tokens regardless of window size.
-// 1M thresholds are therefore more aggressive, not less.
+// Configure applies [plugin.ctx] thresholds for c's window size.
+func (c *ContextWindow) Configure(pcfg config.PluginConfig) {
+	c.Levels = pcfg.CtxLevels(c.IsLarge())
+}
+
 func (c ContextWindow) severity() string {
-	if c.IsLarge() {
-		// 30% of 1M = 300K tokens (degradation onset)
-		// 60% of 1M = 600K tokens (reliability lost)
-		switch {
-		case c.UsedPct >= 60:
-			return "red"
-		case c.UsedPct >= 30:
-			return "yellow"
-		}
-	} else {
-		switch {
-		case c.UsedPct >= 85 || c.Exceeds200k:
-			return "red"
-		case c.UsedPct >= 50:
-			return "yellow"
-		}
+	lv := c.levels()
+	switch {
+	case c.UsedPct >= lv.Error || !c.IsLarge() && c.Exceeds200k:
+		return "red"
+	case c.UsedPct >= lv.Warn:
+		return "yellow"
 	}
 	return "dim"
 }
 
+// levels are the configured cut-offs, or the defaults for the window size
+// (see config.DefaultCtxLarge for why 1M is stricter).
+func (c ContextWindow) levels() config.Levels {
+	if c.Levels != (config.Levels{}) {
+		return c.Levels
+	}
+	return config.PluginConfig{}.CtxLevels(c.IsLarge())
+}
+
 func (c ContextWindow) Render() types.Segment {
 	style := c.severity()
 	text := fmt.Sprintf("%.0f%%", c.UsedPct)
 	if style == "red" {
 		text += "!"
 	}
+	short := text
+	switch {
+	case c.Delta > 0:
+		text += fmt.Sprintf(" +%.0f", c.Delta)
+	case c.TurnsLeft > 0 && style != "red":
+		text += fmt.Sprintf(" ~%dt", c.TurnsLeft)
+	}
 
 	return types.Segment{
 		Text:     text,
+		Short:    short,
 		Style:    style,
 		Priority: 45,
 	}
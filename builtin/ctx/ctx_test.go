@@ -1,6 +1,10 @@
 package ctx
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/hergert/ccsl/internal/config"
+)
 
 func parse(t *testing.T, raw map[string]any) ContextWindow {
 	t.Helper()
@@ -82,3 +86,38 @@ func TestSeverityThresholds(t *testing.T) {
 		})
 	}
 }
+
+func TestConfiguredThresholds(t *testing.T) {
+	warn, errAt := 20.0, 40.0
+	pcfg := config.PluginConfig{Large: config.Thresholds{Warn: &warn, Error: &errAt}}
+
+	large := ContextWindow{UsedPct: 40, WindowSize: 1000000}
+	large.Configure(pcfg)
+	if seg := large.Render(); seg.Style != "red" {
+		t.Errorf("large at 40%% with error 40: style %q, want red", seg.Style)
+	}
+
+	// The standard window keeps its defaults.
+	standard := ContextWindow{UsedPct: 40, WindowSize: 200000}
+	standard.Configure(pcfg)
+	if seg := standard.Render(); seg.Style != "dim" {
+		t.Errorf("standard at 40%%: style %q, want dim", seg.Style)
+	}
+}
+
+func TestRenderShortDropsTrend(t *testing.T) {
+	cases := []struct {
+		c           ContextWindow
+		text, short string
+	}{
+		{ContextWindow{UsedPct: 42, WindowSize: 200000}, "42%", "42%"},
+		{ContextWindow{UsedPct: 42, WindowSize: 200000, Delta: 3}, "42% +3", "42%"},
+		{ContextWindow{UsedPct: 60, WindowSize: 200000, TurnsLeft: 7}, "60% ~7t", "60%"},
+		{ContextWindow{UsedPct: 95, WindowSize: 200000, Delta: 4}, "95%! +4", "95%!"},
+	}
+	for _, tc := range cases {
+		if seg := tc.c.Render(); seg.Text != tc.text || seg.Short != tc.short {
+			t.Errorf("Render(%+v) = %q/%q, want %q/%q", tc.c, seg.Text, seg.Short, tc.text, tc.short)
+		}
+	}
+}
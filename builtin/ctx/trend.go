@@ -0,0 +1,80 @@
+package ctx
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hergert/ccsl/internal/xdg"
+)
+
+// Turns are estimated from the average growth over this many recent turns.
+const trendSamples = 6
+
+// history is the usage seen at each distinct refresh of one session. The
+// statusline refreshes more often than turns happen, so repeats of the last
+// value are not new samples.
+type history struct {
+	WindowSize float64   `json:"window_size"`
+	Pcts       []float64 `json:"pcts"`
+}
+
+func historyPath(sessionID string) string {
+	return filepath.Join(xdg.StateDir(), "ctx", sessionID+".json")
+}
+
+// Track records c's usage for the session and fills in the trend mode asks
+// for: "delta" sets Delta, "turns" sets TurnsLeft. Other modes, or a missing
+// session_id, leave c alone. A drop in usage (/compact, /clear) starts the
+// history over.
+func Track(c *ContextWindow, sessionID, mode string) {
+	if mode != "delta" && mode != "turns" {
+		return
+	}
+	if sessionID == "" || strings.ContainsAny(sessionID, `/\`) {
+		return
+	}
+
+	path := historyPath(sessionID)
+	var h history
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, &h)
+	} else {
+		xdg.PruneSessions(filepath.Dir(path)) // a new session: clear out old ones
+	}
+	n := len(h.Pcts)
+	switch {
+	case n > 0 && h.Pcts[n-1] == c.UsedPct && h.WindowSize == c.WindowSize:
+		// same turn as last refresh
+	case n == 0 || h.Pcts[n-1] > c.UsedPct || h.WindowSize != c.WindowSize:
+		h = history{WindowSize: c.WindowSize, Pcts: []float64{c.UsedPct}}
+		writeHistory(path, h)
+	default:
+		h.Pcts = append(h.Pcts, c.UsedPct)
+		if len(h.Pcts) > trendSamples+1 {
+			h.Pcts = h.Pcts[len(h.Pcts)-trendSamples-1:]
+		}
+		writeHistory(path, h)
+	}
+
+	n = len(h.Pcts)
+	if n < 2 {
+		return
+	}
+	if mode == "delta" {
+		c.Delta = h.Pcts[n-1] - h.Pcts[n-2]
+		return
+	}
+	perTurn := (h.Pcts[n-1] - h.Pcts[0]) / float64(n-1)
+	if left := c.levels().Error - c.UsedPct; left > 0 && perTurn > 0 {
+		c.TurnsLeft = int(math.Ceil(left / perTurn))
+	}
+}
+
+func writeHistory(path string, h history) {
+	if data, err := json.Marshal(h); err == nil {
+		_ = xdg.WriteFileAtomic(path, data)
+	}
+}
@@ -0,0 +1,102 @@
+package ctx
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func track(t *testing.T, session, mode string, pct, size float64) ContextWindow {
+	t.Helper()
+	c := ContextWindow{UsedPct: pct, WindowSize: size}
+	Track(&c, session, mode)
+	return c
+}
+
+func TestTrackDelta(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	if c := track(t, "s1", "delta", 15, 200000); c.Delta != 0 {
+		t.Errorf("first sample Delta = %v, want 0", c.Delta)
+	}
+	c := track(t, "s1", "delta", 18, 200000)
+	if got := c.Render().Text; got != "18% +3" {
+		t.Errorf("Text = %q, want %q", got, "18% +3")
+	}
+	// A refresh without a new turn keeps showing the last turn's growth.
+	if c := track(t, "s1", "delta", 18, 200000); c.Delta != 3 {
+		t.Errorf("repeat Delta = %v, want 3", c.Delta)
+	}
+	if c := track(t, "s2", "delta", 40, 200000); c.Delta != 0 {
+		t.Errorf("other session Delta = %v, want 0", c.Delta)
+	}
+}
+
+func TestTrackTurns(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	for _, pct := range []float64{10, 14, 20} {
+		track(t, "s", "turns", pct, 200000)
+	}
+	// 5 points per turn, 85 - 25 = 60 points left.
+	c := track(t, "s", "turns", 25, 200000)
+	if c.TurnsLeft != 12 {
+		t.Errorf("TurnsLeft = %d, want 12", c.TurnsLeft)
+	}
+	if got := c.Render().Text; got != "25% ~12t" {
+		t.Errorf("Text = %q, want %q", got, "25% ~12t")
+	}
+
+	// The 1M window goes red at 60%.
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	track(t, "s", "turns", 20, 1000000)
+	if c := track(t, "s", "turns", 30, 1000000); c.TurnsLeft != 3 {
+		t.Errorf("large TurnsLeft = %d, want 3", c.TurnsLeft)
+	}
+}
+
+func TestTrackResetsAfterCompact(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	track(t, "s", "delta", 40, 200000)
+	track(t, "s", "delta", 60, 200000)
+	if c := track(t, "s", "delta", 12, 200000); c.Delta != 0 {
+		t.Errorf("Delta after compact = %v, want 0", c.Delta)
+	}
+	if c := track(t, "s", "delta", 14, 200000); c.Delta != 2 {
+		t.Errorf("Delta = %v, want 2", c.Delta)
+	}
+}
+
+func TestTrackOff(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	track(t, "s", "", 10, 200000)
+	if c := track(t, "s", "", 20, 200000); c.Delta != 0 || c.TurnsLeft != 0 {
+		t.Errorf("trend off: %+v", c)
+	}
+	if c := track(t, "", "delta", 20, 200000); c.Delta != 0 {
+		t.Errorf("no session: Delta = %v", c.Delta)
+	}
+}
+
+func TestTrackPrunesOldSessions(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	track(t, "old", "delta", 10, 200000)
+	track(t, "recent", "delta", 10, 200000)
+	long := time.Now().Add(-30 * 24 * time.Hour)
+	if err := os.Chtimes(historyPath("old"), long, long); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only a new session's first write prunes.
+	track(t, "recent", "delta", 12, 200000)
+	if _, err := os.Stat(historyPath("old")); err != nil {
+		t.Fatalf("pruned by an existing session: %v", err)
+	}
+	track(t, "new", "delta", 10, 200000)
+	if _, err := os.Stat(historyPath("old")); !os.IsNotExist(err) {
+		t.Errorf("stale history kept: %v", err)
+	}
+	for _, id := range []string{"recent", "new"} {
+		if _, err := os.Stat(historyPath(id)); err != nil {
+			t.Errorf("%s history: %v", id, err)
+		}
+	}
+}
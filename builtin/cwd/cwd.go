@@ -1,40 +1,93 @@
 package cwd
 
 import (
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/hergert/ccsl/internal/types"
 )
 
 type Dir struct {
 	Path string
+	Home string
+	Host string // for the file:// link
 }
 
 func Parse(raw map[string]any) Dir {
+	d := Dir{}
+	d.Home, _ = os.UserHomeDir()
+	d.Host, _ = os.Hostname()
 	if ws, ok := raw["workspace"].(map[string]any); ok {
 		if dir, ok := ws["current_dir"].(string); ok && dir != "" {
-			return Dir{Path: dir}
+			d.Path = dir
+			return d
 		}
 	}
 	if dir, err := os.Getwd(); err == nil {
-		return Dir{Path: dir}
+		d.Path = dir
+		return d
 	}
 	return Dir{}
 }
 
+// Render shows the directory's name; its long form is the whole path with
+// the home directory as "~", and its short form that path with every
+// parent cut to its first letter (~/s/p/project).
 func (d Dir) Render() types.Segment {
 	if d.Path == "" {
 		return types.Segment{}
 	}
-
 	name := filepath.Base(d.Path)
 	if name == "" || name == "/" {
 		name = d.Path
 	}
-
+	long := d.tildePath()
 	return types.Segment{
 		Text:     name,
+		Short:    abbreviate(long),
+		Long:     long,
+		Link:     (&url.URL{Scheme: "file", Host: d.Host, Path: filepath.ToSlash(d.Path)}).String(),
 		Priority: 80,
+		Truncate: "shorten",
+	}
+}
+
+func (d Dir) tildePath() string {
+	p := filepath.Clean(d.Path)
+	if d.Home == "" || d.Home == "/" {
+		return p
+	}
+	home := filepath.Clean(d.Home)
+	if p == home {
+		return "~"
+	}
+	if rest, ok := strings.CutPrefix(p, home+string(filepath.Separator)); ok {
+		return "~" + string(filepath.Separator) + rest
+	}
+	return p
+}
+
+// abbreviate keeps the last element of path and the first letter of each
+// parent (two for dot-directories, so .config stays recognizable as .c).
+func abbreviate(path string) string {
+	parts := strings.Split(path, string(filepath.Separator))
+	for i, part := range parts[:len(parts)-1] {
+		if part == "" || part == "~" {
+			continue
+		}
+		n := 1
+		if strings.HasPrefix(part, ".") && len(part) > 1 {
+			n = 2
+		}
+		end := 0
+		for j := 0; j < n && end < len(part); j++ {
+			_, size := utf8.DecodeRuneInString(part[end:])
+			end += size
+		}
+		parts[i] = part[:end]
 	}
+	return strings.Join(parts, string(filepath.Separator))
 }
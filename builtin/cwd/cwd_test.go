@@ -0,0 +1,37 @@
+package cwd
+
+import "testing"
+
+func TestRenderLink(t *testing.T) {
+	seg := Dir{Path: "/home/me/my proj", Home: "/home/me", Host: "box"}.Render()
+	if want := "file://box/home/me/my%20proj"; seg.Link != want {
+		t.Errorf("Link = %q, want %q", seg.Link, want)
+	}
+}
+
+func TestRenderForms(t *testing.T) {
+	cases := []struct {
+		name  string
+		dir   Dir
+		text  string
+		short string
+		long  string
+	}{
+		{"under home", Dir{Path: "/home/me/src/proj/ccsl", Home: "/home/me"}, "ccsl", "~/s/p/ccsl", "~/src/proj/ccsl"},
+		{"home itself", Dir{Path: "/home/me", Home: "/home/me"}, "me", "~", "~"},
+		{"outside home", Dir{Path: "/var/lib/app", Home: "/home/me"}, "app", "/v/l/app", "/var/lib/app"},
+		{"dot directory", Dir{Path: "/home/me/.config/ccsl", Home: "/home/me"}, "ccsl", "~/.c/ccsl", "~/.config/ccsl"},
+		{"home prefix is not home", Dir{Path: "/home/meg/x", Home: "/home/me"}, "x", "/h/m/x", "/home/meg/x"},
+		{"multibyte parent", Dir{Path: "/home/me/über/x", Home: "/home/me"}, "x", "~/ü/x", "~/über/x"},
+		{"root", Dir{Path: "/"}, "/", "/", "/"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			seg := tc.dir.Render()
+			if seg.Text != tc.text || seg.Short != tc.short || seg.Long != tc.long {
+				t.Errorf("Render = %q / %q / %q, want %q / %q / %q",
+					seg.Text, seg.Short, seg.Long, tc.text, tc.short, tc.long)
+			}
+		})
+	}
+}
@@ -30,10 +30,11 @@ func (d Duration) Render() types.Segment {
 	hours := total / 60
 	mins := total % 60
 
-	var text string
+	var text, short string
 	switch {
 	case hours > 0:
 		text = fmt.Sprintf("%dh%dm", hours, mins)
+		short = fmt.Sprintf("%dh", hours)
 	case mins > 0:
 		text = fmt.Sprintf("%dm", mins)
 	default:
@@ -42,6 +43,7 @@ func (d Duration) Render() types.Segment {
 
 	return types.Segment{
 		Text:     text,
+		Short:    short,
 		Style:    "dim",
 		Priority: 25,
 	}
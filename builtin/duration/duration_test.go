@@ -0,0 +1,27 @@
+package duration
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	cases := []struct {
+		ms          float64
+		text, short string
+	}{
+		{42_000, "42s", ""},
+		{498_000, "8m", ""},
+		{3_600_000, "1h0m", "1h"},
+		{8_130_000, "2h15m", "2h"},
+	}
+	for _, tc := range cases {
+		d, ok := Parse(map[string]any{"cost": map[string]any{"total_duration_ms": tc.ms}})
+		if !ok {
+			t.Fatalf("Parse(%v) not ok", tc.ms)
+		}
+		if seg := d.Render(); seg.Text != tc.text || seg.Short != tc.short {
+			t.Errorf("Render(%vms) = %q/%q, want %q/%q", tc.ms, seg.Text, seg.Short, tc.text, tc.short)
+		}
+	}
+	if _, ok := Parse(map[string]any{"cost": map[string]any{"total_duration_ms": 0.0}}); ok {
+		t.Error("Parse of a zero duration is ok")
+	}
+}
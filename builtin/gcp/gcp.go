@@ -2,6 +2,7 @@ package gcp
 
 import (
 	"bufio"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -44,16 +45,24 @@ func Render(raw map[string]any) types.Segment {
 		text += shortenEmail(account)
 	}
 
+	short := text
+	link := "https://console.cloud.google.com/"
+	if project != "" {
+		link += "home/dashboard?project=" + url.QueryEscape(project)
+	}
 	if configCount > 1 || (configName != "" && configName != "default") {
 		text += "@" + configName
 	}
 
 	if mismatch {
 		text += "⚠"
+		short += "⚠"
 	}
 
 	return types.Segment{
 		Text:     text,
+		Short:    short,
+		Link:     link,
 		Style:    "dim",
 		Priority: 35,
 	}
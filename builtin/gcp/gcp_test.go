@@ -0,0 +1,57 @@
+package gcp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// gcloud writes one file per named configuration and the active name.
+func setup(t *testing.T, active string, configs map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "configurations"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, body := range configs {
+		if err := os.WriteFile(filepath.Join(dir, "configurations", "config_"+name), []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "active_config"), []byte(active+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CLOUDSDK_CONFIG", dir)
+	t.Setenv("CLOUDSDK_CORE_ACCOUNT", "")
+	t.Setenv("CLOUDSDK_CORE_PROJECT", "")
+	t.Setenv("CLOUDSDK_ACTIVE_CONFIG_NAME", "")
+}
+
+func TestRender(t *testing.T) {
+	work := "[core]\naccount = ann@example.com\nproject = web-prod\n"
+	cases := []struct {
+		name        string
+		active      string
+		configs     map[string]string
+		env         map[string]string
+		text, short string
+	}{
+		{"default only", "default", map[string]string{"default": work}, nil, "gcp:web-prod", "gcp:web-prod"},
+		{"named config", "work", map[string]string{"default": "", "work": work}, nil, "gcp:web-prod@work", "gcp:web-prod"},
+		{"account only", "default", map[string]string{"default": "[core]\naccount = ann@example.com\n"}, nil, "gcp:ann", "gcp:ann"},
+		{"env project differs", "work", map[string]string{"work": work},
+			map[string]string{"CLOUDSDK_CORE_PROJECT": "web-dev"}, "gcp:web-dev@work⚠", "gcp:web-dev⚠"},
+		{"nothing set", "default", map[string]string{"default": ""}, nil, "", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			setup(t, tc.active, tc.configs)
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			if seg := Render(nil); seg.Text != tc.text || seg.Short != tc.short {
+				t.Errorf("Render = %q/%q, want %q/%q", seg.Text, seg.Short, tc.text, tc.short)
+			}
+		})
+	}
+}
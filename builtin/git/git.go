@@ -2,6 +2,7 @@ package git
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -9,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/hergert/ccsl/internal/config"
+	"github.com/hergert/ccsl/internal/gitrepo"
 	"github.com/hergert/ccsl/internal/palette"
 	"github.com/hergert/ccsl/internal/types"
 )
@@ -16,52 +18,86 @@ import (
 type Status struct {
 	Branch   string
 	Dirty    bool
+	Unknown  bool // Dirty couldn't be checked in time
 	Ahead    int
 	Behind   int
 	HasStash bool
+	URL      string // the branch on its forge, for [theme] hyperlinks
 }
 
-func (s Status) Render(ansi bool) types.Segment {
-	text := s.Branch
-	if s.Dirty {
-		text += "*"
+// Render colors the ahead/behind/stash indicators through pal; a nil pal
+// renders plain text. The short form cuts the branch to its last path
+// element (feature/login is login).
+func (s Status) Render(pal *palette.Palette) types.Segment {
+	var marks string
+	switch {
+	case s.Dirty:
+		marks += "*"
+	case s.Unknown:
+		marks += pal.Apply("?", "dim")
 	}
 	if s.Ahead > 0 {
-		ind := fmt.Sprintf("⇡%d", s.Ahead)
-		if ansi {
-			ind = palette.Yellow + ind + palette.Reset
-		}
-		text += ind
+		marks += pal.Apply(fmt.Sprintf("⇡%d", s.Ahead), "warn")
 	}
 	if s.Behind > 0 {
-		ind := fmt.Sprintf("⇣%d", s.Behind)
-		if ansi {
-			ind = palette.Red + ind + palette.Reset
-		}
-		text += ind
+		marks += pal.Apply(fmt.Sprintf("⇣%d", s.Behind), "error")
 	}
 	if s.HasStash {
-		ind := "≡"
-		if ansi {
-			ind = palette.Dim + ind + palette.Reset
-		}
-		text += ind
+		marks += pal.Apply("≡", "dim")
 	}
 
+	var short string
+	if i := strings.LastIndexByte(s.Branch, '/'); i >= 0 && i < len(s.Branch)-1 {
+		short = s.Branch[i+1:] + marks
+	}
 	return types.Segment{
-		Text:     text,
+		Text:     s.Branch + marks,
+		Short:    short,
+		Link:     s.URL,
 		Style:    "dim",
 		Priority: 60,
+		Truncate: "shorten",
+	}
+}
+
+// Collect reads the status of the repository containing dir, normally the
+// statusline's workspace.current_dir rather than ccsl's own working directory.
+//
+// Tracked-only status is read in-process; untracked files need git's ignore
+// rules, so that mode (and anything the reader can't handle) runs git. When
+// neither finishes in time, the branch is still shown, with a dim ? for the
+// dirty check that didn't.
+func Collect(ctx context.Context, dir string, cfg *config.Config) (Status, bool) {
+	if cfg.Plugin["git"].Untracked {
+		return collectExec(ctx, dir, true)
+	}
+	s, err := readStatus(ctx, dir)
+	switch {
+	case err == nil:
+		return s, true
+	case errors.Is(err, gitrepo.ErrNotRepo):
+		return Status{}, false
+	}
+	if ctx.Err() == nil {
+		if full, ok := collectExec(ctx, dir, false); ok {
+			return full, true
+		}
+	}
+	if s.Branch == "" {
+		return Status{}, false
 	}
+	s.Unknown = true
+	return s, true
 }
 
-func Collect(ctx context.Context, cfg *config.Config) (Status, bool) {
+func collectExec(ctx context.Context, dir string, untracked bool) (Status, bool) {
 	args := []string{"status", "--porcelain=v2", "--branch", "--untracked-files=no"}
-	if pcfg, exists := cfg.Plugin["git"]; exists && pcfg.Untracked {
+	if untracked {
 		args = []string{"status", "--porcelain=v2", "--branch"}
 	}
 
 	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
 	out, err := cmd.Output()
 	if err != nil {
 		return Status{}, false
@@ -88,6 +124,7 @@ func Collect(ctx context.Context, cfg *config.Config) (Status, bool) {
 
 	if s.Branch == "" || s.Branch == "(detached)" {
 		cmd := exec.CommandContext(ctx, "git", "rev-parse", "--short", "HEAD")
+		cmd.Dir = dir
 		if out, err := cmd.Output(); err == nil {
 			s.Branch = strings.TrimSpace(string(out))
 		}
@@ -97,7 +134,7 @@ func Collect(ctx context.Context, cfg *config.Config) (Status, bool) {
 		return Status{}, false
 	}
 
-	if gitDir := findGitDir(ctx); gitDir != "" {
+	if gitDir := findGitDir(ctx, dir); gitDir != "" {
 		if _, err := os.Stat(filepath.Join(gitDir, "refs", "stash")); err == nil {
 			s.HasStash = true
 		}
@@ -106,11 +143,18 @@ func Collect(ctx context.Context, cfg *config.Config) (Status, bool) {
 	return s, true
 }
 
-func findGitDir(ctx context.Context) string {
-	cmd := exec.CommandContext(ctx, "git", "rev-parse", "--git-dir")
+// findGitDir returns the common git dir for dir: refs/stash is shared by all
+// linked worktrees, so it lives there rather than in the per-worktree gitdir.
+func findGitDir(ctx context.Context, dir string) string {
+	cmd := exec.CommandContext(ctx, "git", "rev-parse", "--git-common-dir")
+	cmd.Dir = dir
 	out, err := cmd.Output()
 	if err != nil {
 		return ""
 	}
-	return strings.TrimSpace(string(out))
+	gitDir := strings.TrimSpace(string(out))
+	if gitDir != "" && !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(dir, gitDir)
+	}
+	return gitDir
 }
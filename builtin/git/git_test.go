@@ -0,0 +1,152 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/hergert/ccsl/internal/config"
+	"github.com/hergert/ccsl/internal/palette"
+)
+
+// gitCmd runs git in dir with an isolated identity and no user config.
+func gitCmd(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_CONFIG_GLOBAL="+os.DevNull,
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.com",
+		"GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@example.com",
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
+
+func requireGit(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+}
+
+func initRepo(t *testing.T, dir, branch string) {
+	t.Helper()
+	requireGit(t)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	gitCmd(t, dir, "init", "-q", "-b", branch)
+	writeFile(t, filepath.Join(dir, "README"), "hello\n")
+	gitCmd(t, dir, "add", "README")
+	gitCmd(t, dir, "commit", "-q", "-m", "init")
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func collect(t *testing.T, dir string) Status {
+	t.Helper()
+	s, ok := Collect(context.Background(), dir, &config.Config{})
+	if !ok {
+		t.Fatalf("Collect(%s) returned ok=false", dir)
+	}
+	return s
+}
+
+func TestCollectRunsInWorkspaceDir(t *testing.T) {
+	outer := t.TempDir()
+	initRepo(t, outer, "outer")
+	inner := filepath.Join(outer, "vendor", "inner")
+	initRepo(t, inner, "inner")
+	sub := filepath.Join(inner, "pkg")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := collect(t, outer); s.Branch != "outer" {
+		t.Errorf("outer Branch = %q, want outer", s.Branch)
+	}
+	if s := collect(t, sub); s.Branch != "inner" {
+		t.Errorf("nested Branch = %q, want inner", s.Branch)
+	}
+}
+
+func TestCollectLinkedWorktree(t *testing.T) {
+	root := t.TempDir()
+	main := filepath.Join(root, "main")
+	initRepo(t, main, "main")
+	wt := filepath.Join(root, "wt")
+	gitCmd(t, main, "worktree", "add", "-q", "-b", "feature", wt)
+
+	writeFile(t, filepath.Join(main, "README"), "stashed\n")
+	gitCmd(t, main, "stash", "-q")
+	writeFile(t, filepath.Join(wt, "README"), "dirty\n")
+
+	s := collect(t, wt)
+	if s.Branch != "feature" {
+		t.Errorf("Branch = %q, want feature", s.Branch)
+	}
+	if !s.Dirty {
+		t.Error("expected worktree to be dirty")
+	}
+	if !s.HasStash {
+		t.Error("expected stash from the shared git dir")
+	}
+
+	if s := collect(t, main); s.Branch != "main" || s.Dirty {
+		t.Errorf("main = %+v, want clean main", s)
+	}
+}
+
+func TestCollectOutsideRepo(t *testing.T) {
+	requireGit(t)
+	t.Setenv("GIT_CEILING_DIRECTORIES", os.TempDir())
+	if _, ok := Collect(context.Background(), t.TempDir(), &config.Config{}); ok {
+		t.Error("expected ok=false outside a repository")
+	}
+}
+
+func TestRenderShortBranch(t *testing.T) {
+	cases := []struct {
+		branch, short string
+	}{
+		{"feature/login", "login*"},
+		{"me/fix/oauth-retry", "oauth-retry*"},
+		{"main", ""},
+		{"odd/", ""},
+	}
+	for _, tc := range cases {
+		if got := (Status{Branch: tc.branch, Dirty: true}).Render(nil).Short; got != tc.short {
+			t.Errorf("Render(%q).Short = %q, want %q", tc.branch, got, tc.short)
+		}
+	}
+}
+
+func TestRenderIndicatorsThroughPalette(t *testing.T) {
+	s := Status{Branch: "main", Dirty: true, Ahead: 1, Behind: 2, HasStash: true}
+	if got := s.Render(nil).Text; got != "main*⇡1⇣2≡" {
+		t.Errorf("plain Text = %q", got)
+	}
+	pal := palette.From(&config.Config{Theme: config.ThemeConfig{ANSI: true, Depth: "256"}})
+	want := "main*" + palette.Yellow + "⇡1" + palette.Reset + palette.Red + "⇣2" + palette.Reset + palette.Dim + "≡" + palette.Reset
+	if got := s.Render(pal).Text; got != want {
+		t.Errorf("Text = %q, want %q", got, want)
+	}
+	unknown := Status{Branch: "main", Unknown: true}
+	if got := unknown.Render(pal).Text; got != "main"+palette.Dim+"?"+palette.Reset {
+		t.Errorf("unknown Text = %q", got)
+	}
+	t.Setenv("NO_COLOR", "1")
+	if got := s.Render(palette.From(&config.Config{Theme: config.ThemeConfig{ANSI: true}})).Text; got != "main*⇡1⇣2≡" {
+		t.Errorf("NO_COLOR Text = %q", got)
+	}
+}
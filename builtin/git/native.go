@@ -0,0 +1,42 @@
+package git
+
+import (
+	"context"
+
+	"github.com/hergert/ccsl/internal/gitrepo"
+)
+
+// readStatus answers Collect from the repository files directly, saving up
+// to three git processes per refresh. Any error (gitrepo.ErrUnsupported for
+// split indexes, sparse checkouts and the like) sends Collect back to git.
+// When only the dirty check failed, the status returned with the error
+// still has the branch and ahead/behind.
+func readStatus(ctx context.Context, dir string) (Status, error) {
+	repo, err := gitrepo.Open(dir)
+	if err != nil {
+		return Status{}, err
+	}
+	defer func() { _ = repo.Close() }()
+
+	branch, head, err := repo.Head()
+	if err != nil {
+		return Status{}, err
+	}
+
+	s := Status{Branch: branch}
+	if branch == "" {
+		s.Branch = repo.Abbrev(head)
+	} else if upstream, ok := repo.Upstream(branch); ok && !head.IsZero() {
+		if up, err := repo.ResolveRef(upstream); err == nil {
+			if s.Ahead, s.Behind, err = repo.AheadBehind(ctx, head, up); err != nil {
+				return Status{}, err
+			}
+		}
+	}
+
+	if s.Dirty, err = repo.Dirty(ctx, head); err != nil {
+		return s, err
+	}
+	s.HasStash = repo.HasStash()
+	return s, nil
+}
@@ -0,0 +1,185 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hergert/ccsl/internal/config"
+	"github.com/hergert/ccsl/internal/gitrepo"
+)
+
+// assertMatchesGit checks the in-process reader against `git status`.
+func assertMatchesGit(t *testing.T, dir string) Status {
+	t.Helper()
+	got, err := readStatus(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("readStatus: %v", err)
+	}
+	want, ok := collectExec(context.Background(), dir, false)
+	if !ok {
+		t.Fatal("git status failed")
+	}
+	if got != want {
+		t.Errorf("readStatus = %+v, git = %+v", got, want)
+	}
+	return got
+}
+
+func TestNativeMatchesGit(t *testing.T) {
+	cases := []struct {
+		name  string
+		setup func(t *testing.T, dir string)
+	}{
+		{"clean", func(t *testing.T, dir string) {}},
+		{"modified same size", func(t *testing.T, dir string) {
+			writeFile(t, filepath.Join(dir, "README"), "HELLO\n")
+		}},
+		{"modified then restored", func(t *testing.T, dir string) {
+			writeFile(t, filepath.Join(dir, "README"), "other\n")
+			writeFile(t, filepath.Join(dir, "README"), "hello\n")
+		}},
+		{"touched only", func(t *testing.T, dir string) {
+			future := time.Now().Add(time.Hour)
+			if err := os.Chtimes(filepath.Join(dir, "README"), future, future); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"deleted", func(t *testing.T, dir string) {
+			if err := os.Remove(filepath.Join(dir, "README")); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"mode change", func(t *testing.T, dir string) {
+			if err := os.Chmod(filepath.Join(dir, "README"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"staged new file", func(t *testing.T, dir string) {
+			writeFile(t, filepath.Join(dir, "new.txt"), "new\n")
+			gitCmd(t, dir, "add", "new.txt")
+		}},
+		{"untracked ignored", func(t *testing.T, dir string) {
+			writeFile(t, filepath.Join(dir, "scratch.txt"), "x\n")
+		}},
+		{"detached", func(t *testing.T, dir string) {
+			gitCmd(t, dir, "checkout", "-q", "--detach")
+		}},
+		{"stash", func(t *testing.T, dir string) {
+			writeFile(t, filepath.Join(dir, "README"), "wip\n")
+			gitCmd(t, dir, "stash", "-q")
+		}},
+		{"index v4", func(t *testing.T, dir string) {
+			if err := os.MkdirAll(filepath.Join(dir, "src", "deep"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			writeFile(t, filepath.Join(dir, "src", "deep", "a.go"), "package a\n")
+			writeFile(t, filepath.Join(dir, "src", "deep", "b.go"), "package b\n")
+			gitCmd(t, dir, "add", ".")
+			gitCmd(t, dir, "commit", "-q", "-m", "src")
+			gitCmd(t, dir, "update-index", "--index-version", "4")
+			writeFile(t, filepath.Join(dir, "src", "deep", "b.go"), "package c\n")
+		}},
+		{"packed with deltas", func(t *testing.T, dir string) {
+			body := strings.Repeat("line of text that stays the same\n", 200)
+			for i := 0; i < 5; i++ {
+				writeFile(t, filepath.Join(dir, "big.txt"), body+strings.Repeat("x", i)+"\n")
+				gitCmd(t, dir, "add", "big.txt")
+				gitCmd(t, dir, "commit", "-q", "-m", "big")
+			}
+			gitCmd(t, dir, "gc", "-q", "--aggressive")
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			initRepo(t, dir, "main")
+			tc.setup(t, dir)
+			assertMatchesGit(t, dir)
+		})
+	}
+}
+
+func TestNativeAheadBehind(t *testing.T) {
+	root := t.TempDir()
+	upstream := filepath.Join(root, "upstream")
+	initRepo(t, upstream, "main")
+	clone := filepath.Join(root, "clone")
+	gitCmd(t, root, "clone", "-q", upstream, clone)
+
+	for i := 0; i < 3; i++ {
+		writeFile(t, filepath.Join(upstream, "README"), strings.Repeat("u", i+1))
+		gitCmd(t, upstream, "commit", "-q", "-am", "upstream")
+	}
+	for i := 0; i < 2; i++ {
+		writeFile(t, filepath.Join(clone, "local.txt"), strings.Repeat("l", i+1))
+		gitCmd(t, clone, "add", "local.txt")
+		gitCmd(t, clone, "commit", "-q", "-m", "local")
+	}
+	gitCmd(t, clone, "fetch", "-q")
+	gitCmd(t, clone, "gc", "-q")
+	if loose, _ := filepath.Glob(filepath.Join(clone, ".git", "objects", "??", "*")); len(loose) > 0 {
+		t.Fatalf("expected a fully packed repo, found %d loose objects", len(loose))
+	}
+
+	s := assertMatchesGit(t, clone)
+	if s.Ahead != 2 || s.Behind != 3 {
+		t.Errorf("ahead/behind = %d/%d, want 2/3", s.Ahead, s.Behind)
+	}
+}
+
+func TestNativeUnbornBranch(t *testing.T) {
+	requireGit(t)
+	dir := t.TempDir()
+	gitCmd(t, dir, "init", "-q", "-b", "trunk")
+
+	got, err := readStatus(context.Background(), dir)
+	if err != nil || got.Branch != "trunk" || got.Dirty {
+		t.Errorf("readStatus = %+v, %v; want clean trunk", got, err)
+	}
+}
+
+func TestNativeFallsBackOnUnsupported(t *testing.T) {
+	cases := []struct {
+		name  string
+		setup func(t *testing.T, dir string)
+	}{
+		{"split index", func(t *testing.T, dir string) {
+			gitCmd(t, dir, "update-index", "--split-index")
+		}},
+		{"sparse checkout", func(t *testing.T, dir string) {
+			gitCmd(t, dir, "sparse-checkout", "set", "--no-cone", "/README")
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			initRepo(t, dir, "main")
+			tc.setup(t, dir)
+			if _, err := readStatus(context.Background(), dir); !errors.Is(err, gitrepo.ErrUnsupported) {
+				t.Fatalf("readStatus err = %v, want ErrUnsupported", err)
+			}
+			if s := collect(t, dir); s.Branch != "main" {
+				t.Errorf("Collect fallback Branch = %q, want main", s.Branch)
+			}
+		})
+	}
+}
+
+// TestCollectKeepsBranchWhenDirtyTimesOut: with no time left for the dirty
+// check (native or git), the branch still shows, marked unknown.
+func TestCollectKeepsBranchWhenDirtyTimesOut(t *testing.T) {
+	dir := t.TempDir()
+	initRepo(t, dir, "main")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s, ok := Collect(ctx, dir, &config.Config{})
+	if !ok || s.Branch != "main" || !s.Unknown || s.Dirty {
+		t.Errorf("Collect = %+v, %v; want main with dirty unknown", s, ok)
+	}
+}
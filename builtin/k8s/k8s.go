@@ -0,0 +1,129 @@
+// Package k8s shows the kubectl context and namespace, reading kubeconfig
+// files directly instead of running kubectl.
+package k8s
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hergert/ccsl/internal/types"
+)
+
+// DefaultProduction matches context names that look like production:
+// "prod" or "prd" at the start of a word, so eu-prod and production but not
+// preprod or nonprod.
+const DefaultProduction = `(?i)(^|[^a-z])(prod|prd)`
+
+type Context struct {
+	Name      string
+	Namespace string // "default" when the context sets none
+}
+
+// Current reads the context kubectl would use: current-context and the
+// contexts merged over the KUBECONFIG files as kubectl merges them (the
+// first file to set a value wins), or ~/.kube/config.
+func Current() (Context, bool) {
+	var current string
+	namespaces := make(map[string]string)
+	for _, path := range kubeconfigPaths() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		doc := parseKubeconfig(data)
+		if current == "" {
+			current, _ = doc["current-context"].(string)
+		}
+		contexts, _ := doc["contexts"].([]any)
+		for _, item := range contexts {
+			c, _ := item.(map[string]any)
+			name, _ := c["name"].(string)
+			if _, seen := namespaces[name]; name == "" || seen {
+				continue
+			}
+			body, _ := c["context"].(map[string]any)
+			namespaces[name], _ = body["namespace"].(string)
+		}
+	}
+	if current == "" {
+		return Context{}, false
+	}
+	ns := namespaces[current]
+	if ns == "" {
+		ns = "default"
+	}
+	return Context{Name: current, Namespace: ns}, true
+}
+
+// parseKubeconfig reads a kubeconfig as YAML or, since kubectl accepts it
+// too, as JSON.
+func parseKubeconfig(data []byte) map[string]any {
+	if trimmed := bytes.TrimLeft(data, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '{' {
+		var doc map[string]any
+		_ = json.Unmarshal(trimmed, &doc)
+		return doc
+	}
+	doc, _ := parseYAML(string(data)).(map[string]any)
+	return doc
+}
+
+func kubeconfigPaths() []string {
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		var paths []string
+		for _, p := range filepath.SplitList(env) {
+			if p != "" {
+				paths = append(paths, p)
+			}
+		}
+		return paths
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	return []string{filepath.Join(home, ".kube", "config")}
+}
+
+// Render shows k8s:context/namespace, flagged with ⚠ when the context
+// matches production (DefaultProduction when empty). The short form cuts
+// generated context names to the cluster: an EKS ARN to what follows its
+// last "/", a GKE gke_project_zone_cluster to its last part.
+func (c Context) Render(production string) types.Segment {
+	if production == "" {
+		production = DefaultProduction
+	}
+	text := "k8s:" + c.Name + "/" + c.Namespace
+	short := "k8s:" + shortName(c.Name) + "/" + c.Namespace
+	if short == text {
+		short = ""
+	}
+
+	style := "dim"
+	if re, err := regexp.Compile(production); err == nil && re.MatchString(c.Name) {
+		style = "warn"
+		text += "⚠"
+		if short != "" {
+			short += "⚠"
+		}
+	}
+	return types.Segment{
+		Text:     text,
+		Short:    short,
+		Style:    style,
+		Priority: 35,
+	}
+}
+
+func shortName(name string) string {
+	switch {
+	case strings.HasPrefix(name, "arn:") && strings.Contains(name, "/"):
+		return name[strings.LastIndexByte(name, '/')+1:]
+	case strings.HasPrefix(name, "gke_") && strings.Count(name, "_") >= 3:
+		return name[strings.LastIndexByte(name, '_')+1:]
+	}
+	return name
+}
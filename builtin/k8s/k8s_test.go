@@ -0,0 +1,146 @@
+package k8s
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const kubectlStyle = `apiVersion: v1
+clusters:
+- cluster:
+    certificate-authority-data: LS0tLS1CRUdJTi...
+    server: https://10.0.0.1:6443
+  name: work
+contexts:
+- context:
+    cluster: work
+    namespace: payments   # team default
+    user: ann
+  name: work-prod
+- context:
+    cluster: work
+    user: ann
+  name: "dev"
+current-context: work-prod
+kind: Config
+preferences: {}
+users:
+- name: ann
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      args:
+        - eks
+        - get-token
+      command: aws
+`
+
+func TestParseYAML(t *testing.T) {
+	doc, ok := parseYAML(kubectlStyle).(map[string]any)
+	if !ok {
+		t.Fatalf("parseYAML = %#v", parseYAML(kubectlStyle))
+	}
+	if doc["current-context"] != "work-prod" || doc["kind"] != "Config" {
+		t.Errorf("scalars: %v, %v", doc["current-context"], doc["kind"])
+	}
+	wantContexts := []any{
+		map[string]any{"context": map[string]any{"cluster": "work", "namespace": "payments", "user": "ann"}, "name": "work-prod"},
+		map[string]any{"context": map[string]any{"cluster": "work", "user": "ann"}, "name": "dev"},
+	}
+	if !reflect.DeepEqual(doc["contexts"], wantContexts) {
+		t.Errorf("contexts = %#v", doc["contexts"])
+	}
+	users := doc["users"].([]any)
+	exec := users[0].(map[string]any)["user"].(map[string]any)["exec"].(map[string]any)
+	if !reflect.DeepEqual(exec["args"], []any{"eks", "get-token"}) || exec["command"] != "aws" {
+		t.Errorf("exec = %#v", exec)
+	}
+	clusters := doc["clusters"].([]any)
+	if server := clusters[0].(map[string]any)["cluster"].(map[string]any)["server"]; server != "https://10.0.0.1:6443" {
+		t.Errorf("server = %v", server)
+	}
+}
+
+func TestParseYAMLIndentedSequence(t *testing.T) {
+	doc := parseYAML(`# written by another tool
+contexts:
+  - name: 'it''s'
+    context:
+      namespace: "a # b"
+  - name: plain
+current-context: plain
+`).(map[string]any)
+	want := []any{
+		map[string]any{"name": "it's", "context": map[string]any{"namespace": "a # b"}},
+		map[string]any{"name": "plain"},
+	}
+	if !reflect.DeepEqual(doc["contexts"], want) || doc["current-context"] != "plain" {
+		t.Errorf("doc = %#v", doc)
+	}
+}
+
+func TestCurrentMergesKubeconfig(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first")
+	second := filepath.Join(dir, "second")
+	write := func(path, body string) {
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The first file names the context, the second defines it; a later
+	// definition of the same context is ignored.
+	write(first, "current-context: staging\ncontexts:\n- name: other\n  context: {}\n")
+	write(second, "contexts:\n- name: staging\n  context:\n    namespace: web\n- name: staging\n  context:\n    namespace: ignored\ncurrent-context: ignored\n")
+	t.Setenv("KUBECONFIG", first+string(os.PathListSeparator)+filepath.Join(dir, "missing")+string(os.PathListSeparator)+second)
+
+	c, ok := Current()
+	if !ok || c != (Context{Name: "staging", Namespace: "web"}) {
+		t.Errorf("Current = %+v, %v", c, ok)
+	}
+
+	// kubectl reads JSON kubeconfigs too.
+	jsonConfig := filepath.Join(dir, "json")
+	write(jsonConfig, `
+  {"kind": "Config", "current-context": "ci",
+   "contexts": [{"name": "ci", "context": {"cluster": "ci", "namespace": "runners"}}]}`)
+	t.Setenv("KUBECONFIG", jsonConfig+string(os.PathListSeparator)+second)
+	if c, ok := Current(); !ok || c != (Context{Name: "ci", Namespace: "runners"}) {
+		t.Errorf("JSON Current = %+v, %v", c, ok)
+	}
+
+	t.Setenv("KUBECONFIG", first)
+	if c, _ := Current(); c.Namespace != "default" {
+		t.Errorf("undefined context namespace = %q, want default", c.Namespace)
+	}
+
+	t.Setenv("KUBECONFIG", "")
+	t.Setenv("HOME", dir)
+	if _, ok := Current(); ok {
+		t.Error("Current without a kubeconfig is ok")
+	}
+}
+
+func TestRender(t *testing.T) {
+	cases := []struct {
+		ctx, production    string
+		text, short, style string
+	}{
+		{"dev", "", "k8s:dev/web", "", "dim"},
+		{"eu-prod", "", "k8s:eu-prod/web⚠", "", "warn"},
+		{"production", "", "k8s:production/web⚠", "", "warn"},
+		{"preprod", "", "k8s:preprod/web", "", "dim"},
+		{"nonprod", "", "k8s:nonprod/web", "", "dim"},
+		{"live", "^live$", "k8s:live/web⚠", "", "warn"},
+		{"arn:aws:eks:eu-west-1:123456789012:cluster/prd-main", "", "k8s:arn:aws:eks:eu-west-1:123456789012:cluster/prd-main/web⚠", "k8s:prd-main/web⚠", "warn"},
+		{"gke_acme-dev_europe-west1_apps", "", "k8s:gke_acme-dev_europe-west1_apps/web", "k8s:apps/web", "dim"},
+	}
+	for _, tc := range cases {
+		seg := Context{Name: tc.ctx, Namespace: "web"}.Render(tc.production)
+		if seg.Text != tc.text || seg.Short != tc.short || seg.Style != tc.style {
+			t.Errorf("%s: Render = %q/%q/%s, want %q/%q/%s", tc.ctx, seg.Text, seg.Short, seg.Style, tc.text, tc.short, tc.style)
+		}
+	}
+}
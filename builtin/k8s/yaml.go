@@ -0,0 +1,187 @@
+package k8s
+
+import (
+	"strconv"
+	"strings"
+)
+
+// parseYAML reads the block-style subset of YAML that kubectl and cloud
+// CLIs write kubeconfigs in: nested mappings and sequences of plain or
+// quoted scalars. Block scalars (| and >) and flow collections come back
+// as empty strings; anchors, tags and multi-document files aren't handled.
+// Mappings are map[string]any, sequences []any, scalars string.
+func parseYAML(data string) any {
+	var lines []yamlLine
+	for _, raw := range strings.Split(data, "\n") {
+		text := stripComment(strings.TrimRight(raw, " \t\r"))
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		lines = append(lines, yamlLine{indent: len(text) - len(trimmed), text: trimmed})
+	}
+	p := &yamlParser{lines: lines}
+	if len(lines) == 0 {
+		return nil
+	}
+	return p.block(lines[0].indent)
+}
+
+type yamlLine struct {
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func (p *yamlParser) peek() (yamlLine, bool) {
+	if p.pos >= len(p.lines) {
+		return yamlLine{}, false
+	}
+	return p.lines[p.pos], true
+}
+
+func isItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// block parses the mapping or sequence whose lines start at indent.
+func (p *yamlParser) block(indent int) any {
+	if l, ok := p.peek(); ok && isItem(l.text) {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+func (p *yamlParser) sequence(indent int) []any {
+	var items []any
+	for {
+		l, ok := p.peek()
+		if !ok || l.indent != indent || !isItem(l.text) {
+			return items
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(l.text, "-"), " ")
+		if rest == "" {
+			p.pos++
+			items = append(items, p.nested(indent))
+			continue
+		}
+		if _, _, isKey := splitKey(rest); !isKey && !isItem(rest) {
+			p.pos++
+			items = append(items, scalar(rest))
+			p.skip(indent)
+			continue
+		}
+		// "- key: value" opens a mapping at the column of its key.
+		p.lines[p.pos] = yamlLine{indent: indent + len(l.text) - len(rest), text: rest}
+		items = append(items, p.block(p.lines[p.pos].indent))
+	}
+}
+
+func (p *yamlParser) mapping(indent int) map[string]any {
+	m := make(map[string]any)
+	for {
+		l, ok := p.peek()
+		if !ok || l.indent != indent || isItem(l.text) {
+			return m
+		}
+		key, value, isKey := splitKey(l.text)
+		p.pos++
+		if !isKey {
+			continue
+		}
+		switch {
+		case value == "":
+			m[key] = p.nested(indent)
+		case value[0] == '|' || value[0] == '>':
+			p.skip(indent)
+			m[key] = ""
+		default:
+			m[key] = scalar(value)
+			p.skip(indent) // a plain scalar continued on deeper lines
+		}
+	}
+}
+
+// nested parses the value of a key or item that continues on the lines
+// below: anything indented deeper, or a sequence at the key's own indent,
+// which YAML allows for mapping values.
+func (p *yamlParser) nested(indent int) any {
+	l, ok := p.peek()
+	switch {
+	case !ok:
+		return nil
+	case l.indent > indent:
+		return p.block(l.indent)
+	case l.indent == indent && isItem(l.text):
+		return p.sequence(indent)
+	}
+	return nil
+}
+
+func (p *yamlParser) skip(indent int) {
+	for l, ok := p.peek(); ok && l.indent > indent; l, ok = p.peek() {
+		p.pos++
+	}
+}
+
+// splitKey splits "key: value" and "key:"; a colon inside a plain value
+// (a URL) needs the space after it to count.
+func splitKey(text string) (key, value string, ok bool) {
+	if strings.HasPrefix(text, `"`) || strings.HasPrefix(text, "'") {
+		end := strings.IndexByte(text[1:], text[0])
+		if end < 0 {
+			return "", "", false
+		}
+		key, text = scalar(text[:end+2]), text[end+2:]
+		if text != ":" && !strings.HasPrefix(text, ": ") {
+			return "", "", false
+		}
+		return key, strings.TrimSpace(text[1:]), true
+	}
+	if strings.HasSuffix(text, ":") {
+		return text[:len(text)-1], "", true
+	}
+	if i := strings.Index(text, ": "); i > 0 {
+		return text[:i], strings.TrimSpace(text[i+2:]), true
+	}
+	return "", "", false
+}
+
+func scalar(s string) string {
+	switch {
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		if u, err := strconv.Unquote(s); err == nil {
+			return u
+		}
+		return s[1 : len(s)-1]
+	case len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'':
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+	case s == "~" || s == "null" || strings.HasPrefix(s, "[") || strings.HasPrefix(s, "{"):
+		return ""
+	}
+	return s
+}
+
+// stripComment drops a " #" comment outside quotes.
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || s[i-1] == ' ' || s[i-1] == '-' || s[i-1] == ':' {
+				quote = c
+			}
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return strings.TrimRight(s[:i], " \t")
+		}
+	}
+	return s
+}
@@ -8,6 +8,7 @@ import (
 
 type Model struct {
 	DisplayName string
+	ID          string // e.g. claude-opus-4-8, for pricing
 }
 
 func Parse(raw map[string]any) Model {
@@ -16,14 +17,26 @@ func Parse(raw map[string]any) Model {
 		if name, ok := data["display_name"].(string); ok && name != "" {
 			m.DisplayName = strings.TrimSpace(name)
 		}
+		m.ID, _ = data["id"].(string)
 	}
 	return m
 }
 
+// Compact drops the parenthesized context size from a display name:
+// "Opus 4.8 (1M context)" is "Opus 4.8".
+func Compact(name string) string {
+	if i := strings.IndexByte(name, '('); i > 0 {
+		return strings.TrimSpace(name[:i])
+	}
+	return name
+}
+
 func (m Model) Render() types.Segment {
 	return types.Segment{
 		Text:     m.DisplayName,
+		Short:    Compact(m.DisplayName),
 		Style:    "bold",
 		Priority: 90,
+		Truncate: "shorten",
 	}
 }
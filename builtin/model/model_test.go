@@ -0,0 +1,37 @@
+package model
+
+import "testing"
+
+func TestCompact(t *testing.T) {
+	cases := map[string]string{
+		"Opus 4.8 (1M context)": "Opus 4.8",
+		"Sonnet 4.5(beta)":      "Sonnet 4.5",
+		"Opus 4.8":              "Opus 4.8",
+		"Claude":                "Claude",
+		"":                      "",
+		// Nothing before the parenthesis: better the whole name than none.
+		"(1M context)": "(1M context)",
+	}
+	for in, want := range cases {
+		if got := Compact(in); got != want {
+			t.Errorf("Compact(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	cases := []struct {
+		raw         map[string]any
+		text, short string
+	}{
+		{map[string]any{"model": map[string]any{"display_name": " Opus 4.8 (1M context) "}}, "Opus 4.8 (1M context)", "Opus 4.8"},
+		{map[string]any{"model": map[string]any{"display_name": "Haiku 4.5"}}, "Haiku 4.5", "Haiku 4.5"},
+		{map[string]any{}, "Claude", "Claude"},
+	}
+	for _, tc := range cases {
+		seg := Parse(tc.raw).Render()
+		if seg.Text != tc.text || seg.Short != tc.short {
+			t.Errorf("Render(%v) = %q/%q, want %q/%q", tc.raw, seg.Text, seg.Short, tc.text, tc.short)
+		}
+	}
+}
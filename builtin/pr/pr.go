@@ -14,6 +14,7 @@ import (
 type PR struct {
 	Number      int
 	ReviewState string
+	URL         string // from the status line when given, else the git remote
 }
 
 func Parse(raw map[string]any) (PR, bool) {
@@ -29,6 +30,9 @@ func Parse(raw map[string]any) (PR, bool) {
 	if rs, ok := p["review_state"].(string); ok {
 		pr.ReviewState = rs
 	}
+	if u, ok := p["url"].(string); ok {
+		pr.URL = u
+	}
 
 	if pr.Number == 0 {
 		return PR{}, false
@@ -36,7 +40,9 @@ func Parse(raw map[string]any) (PR, bool) {
 	return pr, true
 }
 
-func (p PR) Render(ansi bool) types.Segment {
+// Render colors the changes-requested mark through pal; a nil pal renders
+// plain text.
+func (p PR) Render(pal *palette.Palette) types.Segment {
 	text := fmt.Sprintf("#%d", p.Number)
 
 	// review_state is optional; match loosely so unknown values degrade silently.
@@ -44,15 +50,12 @@ func (p PR) Render(ansi bool) types.Segment {
 	case strings.Contains(p.ReviewState, "approv"):
 		text += "✓"
 	case strings.Contains(p.ReviewState, "change"):
-		ind := "✗"
-		if ansi {
-			ind = palette.Red + ind + palette.Reset
-		}
-		text += ind
+		text += pal.Apply("✗", "error")
 	}
 
 	return types.Segment{
 		Text:     text,
+		Link:     p.URL,
 		Style:    "dim",
 		Priority: 58, // just below git (60): truncates after the branch
 	}
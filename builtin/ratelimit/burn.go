@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hergert/ccsl/internal/cache"
+	"github.com/hergert/ccsl/internal/config"
+	"github.com/hergert/ccsl/internal/xdg"
+)
+
+const (
+	defaultBurnWindow = 30 * time.Minute
+	defaultBurnFloor  = 50
+	// Shorter spans make the rate too noisy to project from.
+	minBurnSpan = 2 * time.Minute
+)
+
+type sample struct {
+	At  int64   `json:"t"`
+	Pct float64 `json:"pct"`
+}
+
+type windowHistory struct {
+	ResetsAt int64    `json:"resets_at"`
+	Samples  []sample `json:"samples"`
+}
+
+// Rate limits are per account, so every session shares one history file.
+func historyPath() string {
+	return filepath.Join(xdg.StateDir(), "ratelimit.json")
+}
+
+// historyLock serializes sessions updating the shared history, so one
+// doesn't overwrite another's samples.
+const historyLock = "ratelimit-history"
+
+// Track records the current usage of each window and sets ExhaustsAt from
+// the burn rate over the configured sample window. Best-effort: history that
+// can't be read or written just means no projection, and while another
+// session holds the history this one projects without recording.
+func Track(l *Limits, now time.Time, pcfg config.PluginConfig) {
+	if pcfg.Burn != nil && !*pcfg.Burn {
+		return
+	}
+	span := defaultBurnWindow
+	if pcfg.BurnWindowMin > 0 {
+		span = time.Duration(pcfg.BurnWindowMin) * time.Minute
+	}
+	floor := pcfg.BurnFloorPct
+	if floor <= 0 {
+		floor = defaultBurnFloor
+	}
+
+	locked := cache.TryLock(historyLock)
+	if locked {
+		defer cache.Unlock(historyLock)
+	}
+	history := map[string]*windowHistory{}
+	if data, err := os.ReadFile(historyPath()); err == nil {
+		_ = json.Unmarshal(data, &history)
+	}
+	for key, w := range map[string]*Window{"five_hour": l.FiveHour, "seven_day": l.SevenDay} {
+		if w == nil {
+			continue
+		}
+		h := history[key]
+		if h == nil || h.ResetsAt != w.ResetsAt.Unix() {
+			h = &windowHistory{ResetsAt: w.ResetsAt.Unix()}
+			history[key] = h
+		}
+		h.Samples = record(h.Samples, now, w.UsedPct, span)
+		if w.UsedPct >= floor {
+			w.ExhaustsAt = project(h.Samples, now, w.UsedPct)
+		}
+	}
+	if !locked {
+		return
+	}
+	if data, err := json.Marshal(history); err == nil {
+		_ = xdg.WriteFileAtomic(historyPath(), data)
+	}
+}
+
+// record appends the current sample and drops those older than span. A drop
+// in usage means the window rolled over, so older samples no longer apply.
+func record(samples []sample, now time.Time, pct float64, span time.Duration) []sample {
+	cutoff := now.Add(-span).Unix()
+	kept := samples[:0]
+	for _, s := range samples {
+		if s.At >= cutoff && s.Pct <= pct {
+			kept = append(kept, s)
+		}
+	}
+	return append(kept, sample{At: now.Unix(), Pct: pct})
+}
+
+// project extrapolates linearly from the oldest retained sample.
+func project(samples []sample, now time.Time, pct float64) time.Time {
+	oldest := samples[0]
+	elapsed := now.Sub(time.Unix(oldest.At, 0))
+	if elapsed < minBurnSpan || pct <= oldest.Pct {
+		return time.Time{}
+	}
+	perSecond := (pct - oldest.Pct) / elapsed.Seconds()
+	remaining := time.Duration((100 - pct) / perSecond * float64(time.Second))
+	return now.Add(remaining)
+}
@@ -0,0 +1,136 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hergert/ccsl/internal/cache"
+	"github.com/hergert/ccsl/internal/config"
+	"github.com/hergert/ccsl/internal/palette"
+)
+
+func trackAt(t *testing.T, pct float64, resets, at time.Time, pcfg config.PluginConfig) *Window {
+	t.Helper()
+	l := Limits{FiveHour: &Window{UsedPct: pct, Label: "⁵ʰ", ResetsAt: resets}}
+	Track(&l, at, pcfg)
+	return l.FiveHour
+}
+
+func TestBurnProjection(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	now := time.Now().Truncate(time.Second)
+	resets := now.Add(2*time.Hour + 30*time.Second)
+
+	if w := trackAt(t, 50, resets, now.Add(-10*time.Minute), config.PluginConfig{}); !w.ExhaustsAt.IsZero() {
+		t.Fatalf("single sample should not project, got %v", w.ExhaustsAt)
+	}
+	// 10 points in 10 minutes: the remaining 40 points last 40 minutes.
+	w := trackAt(t, 60, resets, now, config.PluginConfig{})
+	if got := w.ExhaustsAt.Sub(now); got != 40*time.Minute {
+		t.Fatalf("ExhaustsAt in %v, want 40m", got)
+	}
+
+	l := Limits{FiveHour: w}
+	if got := l.Render(nil).Text; got != "60%⁵ʰ→out 39m" && got != "60%⁵ʰ→out 40m" {
+		t.Errorf("Text = %q, want projection", got)
+	}
+	if got := l.Render(ansiPalette()).Text; !strings.Contains(got, palette.Red+"→out") {
+		t.Errorf("projection before reset should be red, got %q", got)
+	}
+}
+
+func TestBurnProjectionAfterResetNotRed(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	now := time.Now().Truncate(time.Second)
+	resets := now.Add(20 * time.Minute)
+	trackAt(t, 50, resets, now.Add(-10*time.Minute), config.PluginConfig{})
+	w := trackAt(t, 60, resets, now, config.PluginConfig{})
+
+	got := Limits{FiveHour: w}.Render(ansiPalette()).Text
+	if !strings.Contains(got, "→out") || strings.Contains(got, palette.Red) {
+		t.Errorf("projection after reset should show uncolored, got %q", got)
+	}
+}
+
+func TestBurnFloorAndDisable(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	resets := now.Add(3 * time.Hour)
+
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	trackAt(t, 20, resets, now.Add(-10*time.Minute), config.PluginConfig{})
+	if w := trackAt(t, 30, resets, now, config.PluginConfig{}); !w.ExhaustsAt.IsZero() {
+		t.Error("usage below the default floor should not project")
+	}
+
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	low := config.PluginConfig{BurnFloorPct: 10}
+	trackAt(t, 20, resets, now.Add(-10*time.Minute), low)
+	if w := trackAt(t, 30, resets, now, low); w.ExhaustsAt.IsZero() {
+		t.Error("burn_floor_pct=10 should project at 30%")
+	}
+
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	off := false
+	disabled := config.PluginConfig{Burn: &off}
+	trackAt(t, 50, resets, now.Add(-10*time.Minute), disabled)
+	if w := trackAt(t, 60, resets, now, disabled); !w.ExhaustsAt.IsZero() {
+		t.Error("burn=false should not project")
+	}
+}
+
+func TestBurnHistoryResetsWithWindow(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	now := time.Now().Truncate(time.Second)
+	trackAt(t, 50, now.Add(time.Hour), now.Add(-10*time.Minute), config.PluginConfig{})
+	if w := trackAt(t, 60, now.Add(6*time.Hour), now, config.PluginConfig{}); !w.ExhaustsAt.IsZero() {
+		t.Error("samples from a previous window should be discarded")
+	}
+}
+
+func TestBurnSampleWindow(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	now := time.Now().Truncate(time.Second)
+	resets := now.Add(4 * time.Hour)
+	short := config.PluginConfig{BurnWindowMin: 5}
+	trackAt(t, 50, resets, now.Add(-10*time.Minute), short)
+	if w := trackAt(t, 60, resets, now, short); !w.ExhaustsAt.IsZero() {
+		t.Error("samples older than burn_window_min should be dropped")
+	}
+}
+
+func TestBurnHistoryLocked(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	now := time.Now().Truncate(time.Second)
+	resets := now.Add(2 * time.Hour)
+	trackAt(t, 50, resets, now.Add(-10*time.Minute), config.PluginConfig{})
+
+	// While another session updates the history, this one reads it but
+	// leaves it alone.
+	if !cache.TryLock(historyLock) {
+		t.Fatal("TryLock failed")
+	}
+	if w := trackAt(t, 60, resets, now, config.PluginConfig{}); w.ExhaustsAt.IsZero() {
+		t.Error("locked history should still project")
+	}
+	cache.Unlock(historyLock)
+
+	data, err := os.ReadFile(historyPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var history map[string]windowHistory
+	if err := json.Unmarshal(data, &history); err != nil || len(history["five_hour"].Samples) != 1 {
+		t.Errorf("history = %s, %v; want only the unlocked sample", data, err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/hergert/ccsl/internal/config"
 	"github.com/hergert/ccsl/internal/palette"
 	"github.com/hergert/ccsl/internal/types"
 )
@@ -12,7 +13,10 @@ import (
 type Window struct {
 	UsedPct  float64
 	ResetsAt time.Time
-	Label    string // superscript label: "⁵ʰ" or "⁷ᵈ"
+	Label    string        // superscript label: "⁵ʰ" or "⁷ᵈ"
+	Levels   config.Levels // zero: config.DefaultRateLimit; see Configure
+	// Projected time usage reaches 100% at the recent burn rate; set by Track.
+	ExhaustsAt time.Time
 }
 
 type Limits struct {
@@ -54,54 +58,90 @@ func parseWindow(rl map[string]any, key, label string) *Window {
 	return w
 }
 
+// Configure applies [plugin.ratelimit] thresholds to each window.
+func (l *Limits) Configure(pcfg config.PluginConfig) {
+	if l.FiveHour != nil {
+		l.FiveHour.Levels = pcfg.RateLimitLevels("five_hour")
+	}
+	if l.SevenDay != nil {
+		l.SevenDay.Levels = pcfg.RateLimitLevels("seven_day")
+	}
+}
+
+func (w *Window) levels() config.Levels {
+	if w.Levels != (config.Levels{}) {
+		return w.Levels
+	}
+	return config.DefaultRateLimit
+}
+
 func (w *Window) severity() string {
+	lv := w.levels()
 	switch {
-	case w.UsedPct >= 90:
-		return "red"
-	case w.UsedPct >= 70:
-		return "yellow"
+	case w.UsedPct >= lv.Error:
+		return "error"
+	case w.UsedPct >= lv.Warn:
+		return "warn"
 	default:
 		return ""
 	}
 }
 
-func (w *Window) format(ansi bool) string {
+// format renders the window's usage, followed unless short by the reset
+// countdown and the burn projection.
+func (w *Window) format(pal *palette.Palette, short bool) string {
 	s := fmt.Sprintf("%.0f%%", w.UsedPct) + w.Label
-	if remaining := time.Until(w.ResetsAt); remaining > 0 && w.UsedPct >= 70 {
-		m := int(remaining.Minutes())
-		switch {
-		case m >= 1440 && (m%1440)/60 == 0:
-			s += fmt.Sprintf("↻%dd", m/1440)
-		case m >= 1440:
-			s += fmt.Sprintf("↻%dd%dh", m/1440, (m%1440)/60)
-		case m >= 60:
-			s += fmt.Sprintf("↻%dh%dm", m/60, m%60)
-		default:
-			s += fmt.Sprintf("↻%dm", m)
-		}
+	if short {
+		return pal.Apply(s, w.severity())
 	}
-	if ansi {
-		switch w.severity() {
-		case "red":
-			s = palette.Red + s + palette.Reset
-		case "yellow":
-			s = palette.Yellow + s + palette.Reset
+	if remaining := time.Until(w.ResetsAt); remaining > 0 && w.UsedPct >= w.levels().Countdown {
+		s += "↻" + formatRemaining(remaining)
+	}
+	s = pal.Apply(s, w.severity())
+	if out := time.Until(w.ExhaustsAt); !w.ExhaustsAt.IsZero() && out > 0 {
+		proj := "→out " + formatRemaining(out)
+		if w.runsOutBeforeReset() {
+			proj = pal.Apply(proj, "error")
 		}
+		s += proj
 	}
 	return s
 }
 
-func (l Limits) Render(ansi bool) types.Segment {
-	var parts []string
-	if l.FiveHour != nil {
-		parts = append(parts, l.FiveHour.format(ansi))
+// runsOutBeforeReset is true when the projection lands before the window
+// resets (or the reset time is unknown).
+func (w *Window) runsOutBeforeReset() bool {
+	return w.ResetsAt.IsZero() || w.ExhaustsAt.Before(w.ResetsAt)
+}
+
+func formatRemaining(d time.Duration) string {
+	m := int(d.Minutes())
+	switch {
+	case m >= 1440 && (m%1440)/60 == 0:
+		return fmt.Sprintf("%dd", m/1440)
+	case m >= 1440:
+		return fmt.Sprintf("%dd%dh", m/1440, (m%1440)/60)
+	case m >= 60:
+		return fmt.Sprintf("%dh%dm", m/60, m%60)
+	default:
+		return fmt.Sprintf("%dm", m)
 	}
-	if l.SevenDay != nil {
-		parts = append(parts, l.SevenDay.format(ansi))
+}
+
+// Render colors each window by severity through pal; a nil pal renders
+// plain text.
+func (l Limits) Render(pal *palette.Palette) types.Segment {
+	var parts, short []string
+	for _, w := range []*Window{l.FiveHour, l.SevenDay} {
+		if w != nil {
+			parts = append(parts, w.format(pal, false))
+			short = append(short, w.format(pal, true))
+		}
 	}
 
 	return types.Segment{
 		Text:     strings.Join(parts, " "),
+		Short:    strings.Join(short, " "),
 		Style:    "dim",
 		Priority: 30,
 	}
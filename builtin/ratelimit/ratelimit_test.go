@@ -1,10 +1,12 @@
 package ratelimit
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/hergert/ccsl/internal/config"
 	"github.com/hergert/ccsl/internal/palette"
 )
 
@@ -46,7 +48,7 @@ func TestRenderJoinsWindows(t *testing.T) {
 		FiveHour: &Window{UsedPct: 12, Label: "⁵ʰ"},
 		SevenDay: &Window{UsedPct: 31, Label: "⁷ᵈ"},
 	}
-	seg := l.Render(false)
+	seg := l.Render(nil)
 	if seg.Text != "12%⁵ʰ 31%⁷ᵈ" {
 		t.Errorf("Text = %q, want %q", seg.Text, "12%⁵ʰ 31%⁷ᵈ")
 	}
@@ -73,8 +75,12 @@ func TestCountdownGate(t *testing.T) {
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
 			l := Limits{FiveHour: &Window{UsedPct: tc.pct, Label: "⁵ʰ", ResetsAt: time.Now().Add(tc.in)}}
-			if got := l.Render(false).Text; got != tc.want {
-				t.Errorf("Text = %q, want %q", got, tc.want)
+			seg := l.Render(nil)
+			if seg.Text != tc.want {
+				t.Errorf("Text = %q, want %q", seg.Text, tc.want)
+			}
+			if want := fmt.Sprintf("%.0f%%⁵ʰ", tc.pct); seg.Short != want {
+				t.Errorf("Short = %q, want %q", seg.Short, want)
 			}
 		})
 	}
@@ -90,13 +96,38 @@ func TestANSISeverity(t *testing.T) {
 	}
 	for _, tc := range cases {
 		l := Limits{FiveHour: &Window{UsedPct: tc.pct, Label: "⁵ʰ"}}
-		got := l.Render(true).Text
+		got := l.Render(ansiPalette()).Text
 		if !strings.Contains(got, tc.code) {
 			t.Errorf("at %v%% expected color %q in %q", tc.pct, tc.code, got)
 		}
 	}
 	l := Limits{FiveHour: &Window{UsedPct: 30, Label: "⁵ʰ"}}
-	if got := l.Render(true).Text; strings.Contains(got, "\x1b[") {
+	if got := l.Render(ansiPalette()).Text; strings.Contains(got, "\x1b[") {
 		t.Errorf("at 30%% expected no color, got %q", got)
 	}
 }
+
+func TestConfiguredThresholds(t *testing.T) {
+	warn, countdown := 40.0, 0.0
+	pcfg := config.PluginConfig{
+		Thresholds: config.Thresholds{Warn: &warn},
+		SevenDay:   config.Thresholds{Countdown: &countdown},
+	}
+	in := time.Now().Add(3*time.Hour + 30*time.Second)
+	l := Limits{
+		FiveHour: &Window{UsedPct: 45, Label: "⁵ʰ", ResetsAt: in},
+		SevenDay: &Window{UsedPct: 10, Label: "⁷ᵈ", ResetsAt: in},
+	}
+	l.Configure(pcfg)
+
+	if got := l.FiveHour.severity(); got != "warn" {
+		t.Errorf("five_hour at 45%% with warn 40: severity %q, want warn", got)
+	}
+	if got := l.Render(nil).Text; got != "45%⁵ʰ 10%⁷ᵈ↻3h0m" {
+		t.Errorf("Text = %q, want countdown only on seven_day", got)
+	}
+}
+
+func ansiPalette() *palette.Palette {
+	return palette.From(&config.Config{Theme: config.ThemeConfig{ANSI: true, Depth: "256"}})
+}
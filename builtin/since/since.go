@@ -0,0 +1,39 @@
+// Package since shows how long ago the user last sent a prompt, per the
+// session's transcript.
+package since
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hergert/ccsl/internal/transcript"
+	"github.com/hergert/ccsl/internal/types"
+)
+
+// Render shows the time since the last prompt: 40s, 12m, 1h5m ago; the
+// short form drops the "ago".
+func Render(s transcript.Summary, now time.Time) types.Segment {
+	if s.LastPrompt.IsZero() {
+		return types.Segment{}
+	}
+	d := now.Sub(s.LastPrompt)
+	if d < 0 {
+		d = 0
+	}
+
+	var short string
+	switch {
+	case d < time.Minute:
+		short = fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		short = fmt.Sprintf("%dm", int(d.Minutes()))
+	default:
+		short = fmt.Sprintf("%dh%dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return types.Segment{
+		Text:     short + " ago",
+		Short:    short,
+		Style:    "dim",
+		Priority: 25,
+	}
+}
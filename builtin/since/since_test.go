@@ -0,0 +1,30 @@
+package since
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hergert/ccsl/internal/transcript"
+)
+
+func TestRender(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		ago  time.Duration
+		want string
+	}{
+		{-time.Second, "0s ago"},
+		{40 * time.Second, "40s ago"},
+		{12*time.Minute + 30*time.Second, "12m ago"},
+		{65 * time.Minute, "1h5m ago"},
+	}
+	for _, tc := range cases {
+		seg := Render(transcript.Summary{LastPrompt: now.Add(-tc.ago)}, now)
+		if seg.Text != tc.want {
+			t.Errorf("%v ago: Text = %q, want %q", tc.ago, seg.Text, tc.want)
+		}
+	}
+	if seg := Render(transcript.Summary{}, now); seg.Text != "" {
+		t.Errorf("no prompt yet shows %q", seg.Text)
+	}
+}
@@ -0,0 +1,45 @@
+package spend
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hergert/ccsl/internal/ledger"
+	"github.com/hergert/ccsl/internal/types"
+)
+
+// Spend is cost across all recorded sessions, from the local ledger.
+type Spend struct {
+	ledger.Totals
+}
+
+func Collect(now time.Time) (Spend, bool) {
+	sessions, err := ledger.Load(ledger.Since(now))
+	if err != nil {
+		return Spend{}, false
+	}
+	s := Spend{ledger.Sum(sessions, now)}
+	if s.Month == 0 && s.Week == 0 {
+		return Spend{}, false
+	}
+	return s, true
+}
+
+func (s Spend) Render() types.Segment {
+	parts := []string{money(s.Today) + "ᵈ", money(s.Week) + "ᵂ", money(s.Month) + "ᴹ"}
+	return types.Segment{
+		Text:     strings.Join(parts, " "),
+		Short:    parts[0],
+		Style:    "dim",
+		Priority: 28,
+	}
+}
+
+// Cents matter for a day, not for a month.
+func money(v float64) string {
+	if v < 10 {
+		return fmt.Sprintf("$%.2f", v)
+	}
+	return fmt.Sprintf("$%.0f", v)
+}
@@ -0,0 +1,73 @@
+package spend
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/hergert/ccsl/internal/ledger"
+)
+
+func TestMoney(t *testing.T) {
+	cases := map[float64]string{
+		0:      "$0.00",
+		0.004:  "$0.00",
+		1.75:   "$1.75",
+		9.99:   "$9.99",
+		10:     "$10",
+		123.6:  "$124",
+		1234.4: "$1234",
+	}
+	for v, want := range cases {
+		if got := money(v); got != want {
+			t.Errorf("money(%g) = %q, want %q", v, got, want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	cases := []struct {
+		totals      ledger.Totals
+		text, short string
+	}{
+		{ledger.Totals{Today: 1.75, Week: 3.75, Month: 12.4}, "$1.75ᵈ $3.75ᵂ $12ᴹ", "$1.75ᵈ"},
+		{ledger.Totals{Week: 0.5, Month: 40}, "$0.00ᵈ $0.50ᵂ $40ᴹ", "$0.00ᵈ"},
+	}
+	for _, tc := range cases {
+		seg := Spend{tc.totals}.Render()
+		if seg.Text != tc.text || seg.Short != tc.short {
+			t.Errorf("Render(%+v) = %q/%q, want %q/%q", tc.totals, seg.Text, seg.Short, tc.text, tc.short)
+		}
+	}
+}
+
+func TestCollect(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	// Wednesday the 1st: the week began on Monday, in the previous month.
+	now := time.Date(2026, 4, 1, 10, 0, 0, 0, time.Local)
+	if _, ok := Collect(now); ok {
+		t.Fatal("Collect on an empty ledger is ok")
+	}
+
+	record := func(session string, at time.Time, cost float64) {
+		t.Helper()
+		raw := map[string]any{"session_id": session, "cost": map[string]any{"total_cost_usd": cost}}
+		if err := ledger.Record(raw, at, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	record("last-week", time.Date(2026, 3, 25, 12, 0, 0, 0, time.Local), 5)
+	// Runs past midnight: $2 on Tuesday the 31st, $1.50 on the 1st.
+	record("overnight", time.Date(2026, 3, 31, 23, 30, 0, 0, time.Local), 2)
+	record("overnight", time.Date(2026, 4, 1, 0, 30, 0, 0, time.Local), 3.5)
+	record("morning", time.Date(2026, 4, 1, 9, 0, 0, 0, time.Local), 0.25)
+
+	s, ok := Collect(now)
+	if !ok {
+		t.Fatal("Collect not ok")
+	}
+	want := ledger.Totals{Today: 1.75, Week: 3.75, Month: 1.75}
+	if math.Abs(s.Today-want.Today) > 1e-9 || math.Abs(s.Week-want.Week) > 1e-9 || math.Abs(s.Month-want.Month) > 1e-9 {
+		t.Errorf("Collect = %+v, want %+v", s.Totals, want)
+	}
+}
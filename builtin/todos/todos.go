@@ -0,0 +1,30 @@
+// Package todos shows the TODO items of the session's latest TodoWrite
+// that are still open.
+package todos
+
+import (
+	"fmt"
+
+	"github.com/hergert/ccsl/internal/transcript"
+	"github.com/hergert/ccsl/internal/types"
+)
+
+// Render shows the open items, and out of how many when some are done:
+// ☐3/5, or ☐5 for a fresh list. Nothing once all are completed.
+func Render(s transcript.Summary) types.Segment {
+	if s.Pending == 0 {
+		return types.Segment{}
+	}
+	text := fmt.Sprintf("☐%d", s.Pending)
+	var short string
+	if s.Pending < s.Todos {
+		short = text
+		text += fmt.Sprintf("/%d", s.Todos)
+	}
+	return types.Segment{
+		Text:     text,
+		Short:    short,
+		Style:    "dim",
+		Priority: 45,
+	}
+}
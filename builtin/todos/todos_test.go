@@ -0,0 +1,25 @@
+package todos
+
+import (
+	"testing"
+
+	"github.com/hergert/ccsl/internal/transcript"
+)
+
+func TestRender(t *testing.T) {
+	cases := []struct {
+		todos, pending int
+		text, short    string
+	}{
+		{0, 0, "", ""},
+		{4, 0, "", ""},
+		{5, 5, "☐5", ""},
+		{5, 3, "☐3/5", "☐3"},
+	}
+	for _, tc := range cases {
+		seg := Render(transcript.Summary{Todos: tc.todos, Pending: tc.pending})
+		if seg.Text != tc.text || seg.Short != tc.short {
+			t.Errorf("%d of %d open: %q/%q, want %q/%q", tc.pending, tc.todos, seg.Text, seg.Short, tc.text, tc.short)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Package tokens shows the session's input and output token totals and how
+// much of the latest request's input was served from the prompt cache.
+package tokens
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/hergert/ccsl/internal/palette"
+	"github.com/hergert/ccsl/internal/transcript"
+	"github.com/hergert/ccsl/internal/types"
+)
+
+// The cache-hit ratio turns yellow below warnHit and red below errorHit
+// percent: a request that misses the cache (it expired, or the prefix
+// changed) pays full price for its whole context.
+const (
+	warnHit  = 80
+	errorHit = 50
+)
+
+type Tokens struct {
+	Input  int64 // session totals, input counting cached tokens too
+	Output int64
+
+	// The latest request's usage, nil when unknown.
+	Last *transcript.Usage
+}
+
+// Parse reads the counts from the statusline's context_window. complete is
+// false when the totals or the latest usage are missing; Fill supplies them
+// from the transcript.
+func Parse(raw map[string]any) (t Tokens, complete bool) {
+	cw, ok := raw["context_window"].(map[string]any)
+	if !ok {
+		return Tokens{}, false
+	}
+	t.Input = count(cw, "total_input_tokens")
+	t.Output = count(cw, "total_output_tokens")
+	if cu, ok := cw["current_usage"].(map[string]any); ok {
+		t.Last = &transcript.Usage{
+			Input:      count(cu, "input_tokens"),
+			Output:     count(cu, "output_tokens"),
+			CacheRead:  count(cu, "cache_read_input_tokens"),
+			CacheWrite: count(cu, "cache_creation_input_tokens"),
+		}
+	}
+	return t, t.Input+t.Output > 0 && t.Last != nil
+}
+
+func count(m map[string]any, key string) int64 {
+	n, _ := m[key].(float64)
+	return int64(n)
+}
+
+// Fill takes what Parse couldn't find from the transcript's usage blocks.
+func (t *Tokens) Fill(s transcript.Summary) {
+	if t.Input+t.Output == 0 {
+		u := s.Usage
+		t.Input, t.Output = u.Input+u.CacheRead+u.CacheWrite, u.Output
+	}
+	if t.Last == nil && s.LastUsage != (transcript.Usage{}) {
+		last := s.LastUsage
+		t.Last = &last
+	}
+}
+
+// HitRatio is the percentage of the latest request's input read from the
+// cache; ok is false without one.
+func (t Tokens) HitRatio() (pct float64, ok bool) {
+	if t.Last == nil {
+		return 0, false
+	}
+	total := t.Last.Input + t.Last.CacheRead + t.Last.CacheWrite
+	if total == 0 {
+		return 0, false
+	}
+	return float64(t.Last.CacheRead) / float64(total) * 100, true
+}
+
+// Render shows 12k↓ 3k↑ 88%⚡, the ratio colored through pal as it drops.
+// The short form is the ratio alone; the long one adds the latest request's
+// cache reads and writes.
+func (t Tokens) Render(pal *palette.Palette) types.Segment {
+	if t.Input+t.Output == 0 {
+		return types.Segment{}
+	}
+	text := fmt.Sprintf("%s↓ %s↑", si(t.Input), si(t.Output))
+	long, short := text, ""
+	if pct, ok := t.HitRatio(); ok {
+		hit := fmt.Sprintf("%.0f%%⚡", math.Floor(pct))
+		switch {
+		case pct < errorHit:
+			hit = pal.Apply(hit, "error")
+		case pct < warnHit:
+			hit = pal.Apply(hit, "warn")
+		}
+		text += " " + hit
+		short = hit
+		long = text + fmt.Sprintf(" r%s w%s", si(t.Last.CacheRead), si(t.Last.CacheWrite))
+	}
+	return types.Segment{
+		Text:     text,
+		Short:    short,
+		Long:     long,
+		Style:    "dim",
+		Priority: 30,
+	}
+}
+
+// si formats n compactly: 950, 1.2k, 12k, 1.2M, 15M.
+func si(n int64) string {
+	units := []struct {
+		size   float64
+		suffix string
+	}{{1e9, "G"}, {1e6, "M"}, {1e3, "k"}}
+	for _, u := range units {
+		if x := float64(n) / u.size; x >= 1 {
+			if x < 10 {
+				return strings.TrimSuffix(fmt.Sprintf("%.1f", math.Floor(x*10)/10), ".0") + u.suffix
+			}
+			return fmt.Sprintf("%.0f%s", math.Floor(x), u.suffix)
+		}
+	}
+	return strconv.FormatInt(n, 10)
+}
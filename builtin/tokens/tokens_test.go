@@ -0,0 +1,97 @@
+package tokens
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hergert/ccsl/internal/config"
+	"github.com/hergert/ccsl/internal/palette"
+	"github.com/hergert/ccsl/internal/transcript"
+)
+
+func TestSI(t *testing.T) {
+	cases := map[int64]string{
+		0:          "0",
+		950:        "950",
+		1000:       "1k",
+		1250:       "1.2k",
+		9999:       "9.9k",
+		12345:      "12k",
+		999999:     "999k",
+		1_200_000:  "1.2M",
+		15_000_000: "15M",
+		2e9:        "2G",
+	}
+	for n, want := range cases {
+		if got := si(n); got != want {
+			t.Errorf("si(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	raw := map[string]any{"context_window": map[string]any{
+		"total_input_tokens":  12000.0,
+		"total_output_tokens": 3400.0,
+		"current_usage": map[string]any{
+			"input_tokens":                200.0,
+			"output_tokens":               50.0,
+			"cache_read_input_tokens":     8800.0,
+			"cache_creation_input_tokens": 1000.0,
+		},
+	}}
+	tok, complete := Parse(raw)
+	want := Tokens{Input: 12000, Output: 3400, Last: &transcript.Usage{Input: 200, Output: 50, CacheRead: 8800, CacheWrite: 1000}}
+	if !complete || !reflect.DeepEqual(tok, want) {
+		t.Errorf("Parse = %+v, %v", tok, complete)
+	}
+	if seg := tok.Render(nil); seg.Text != "12k↓ 3.4k↑ 88%⚡" || seg.Short != "88%⚡" || seg.Long != "12k↓ 3.4k↑ 88%⚡ r8.8k w1k" {
+		t.Errorf("Render = %+v", seg)
+	}
+}
+
+func TestFillFromTranscript(t *testing.T) {
+	s := transcript.Summary{
+		Usage:     transcript.Usage{Input: 100, Output: 2000, CacheRead: 50000, CacheWrite: 5000},
+		LastUsage: transcript.Usage{Input: 10, CacheRead: 990},
+	}
+
+	tok, complete := Parse(map[string]any{"context_window": map[string]any{"used_percentage": 12.0}})
+	if complete {
+		t.Fatal("Parse without counts is complete")
+	}
+	tok.Fill(s)
+	if seg := tok.Render(nil); seg.Text != "55k↓ 2k↑ 99%⚡" {
+		t.Errorf("filled Render = %q", seg.Text)
+	}
+
+	// Totals from the statusline win over the transcript's.
+	tok, _ = Parse(map[string]any{"context_window": map[string]any{"total_input_tokens": 7000.0}})
+	tok.Fill(s)
+	if seg := tok.Render(nil); seg.Text != "7k↓ 0↑ 99%⚡" {
+		t.Errorf("partly filled Render = %q", seg.Text)
+	}
+}
+
+func TestRatioColor(t *testing.T) {
+	pal := palette.From(&config.Config{Theme: config.ThemeConfig{ANSI: true, Depth: "256"}})
+	cases := []struct {
+		read int64
+		want string
+	}{
+		{90, "dim"},
+		{70, "warn"},
+		{20, "error"},
+	}
+	for _, tc := range cases {
+		tok := Tokens{Input: 1, Output: 1, Last: &transcript.Usage{Input: 100 - tc.read, CacheRead: tc.read}}
+		plain := tok.Render(nil).Short
+		want := plain
+		if tc.want != "dim" {
+			want = pal.Apply(plain, tc.want)
+		}
+		if hit := tok.Render(pal).Short; hit != want {
+			t.Errorf("%d%% hit: %q, want %s", tc.read, hit, tc.want)
+		}
+	}
+}
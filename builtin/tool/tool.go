@@ -0,0 +1,43 @@
+// Package tool shows the session's tool use from its transcript: the last
+// tool called ({tool}) and how many calls were made ({tools}).
+package tool
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hergert/ccsl/internal/transcript"
+	"github.com/hergert/ccsl/internal/types"
+)
+
+// Last renders the most recent tool call, MCP tools as server:tool with
+// just the tool as the short form.
+func Last(s transcript.Summary) types.Segment {
+	if s.LastTool == "" {
+		return types.Segment{}
+	}
+	text, short := s.LastTool, ""
+	if rest, ok := strings.CutPrefix(text, "mcp__"); ok {
+		if server, name, ok := strings.Cut(rest, "__"); ok && name != "" {
+			text, short = server+":"+name, name
+		}
+	}
+	return types.Segment{
+		Text:     text,
+		Short:    short,
+		Style:    "dim",
+		Priority: 30,
+	}
+}
+
+// Count renders the number of tool calls so far.
+func Count(s transcript.Summary) types.Segment {
+	if s.ToolCalls == 0 {
+		return types.Segment{}
+	}
+	return types.Segment{
+		Text:     fmt.Sprintf("⚒%d", s.ToolCalls),
+		Style:    "dim",
+		Priority: 20,
+	}
+}
@@ -0,0 +1,33 @@
+package tool
+
+import (
+	"testing"
+
+	"github.com/hergert/ccsl/internal/transcript"
+)
+
+func TestLast(t *testing.T) {
+	cases := []struct {
+		tool, text, short string
+	}{
+		{"", "", ""},
+		{"Bash", "Bash", ""},
+		{"mcp__github__create_issue", "github:create_issue", "create_issue"},
+		{"mcp__odd", "mcp__odd", ""},
+	}
+	for _, tc := range cases {
+		seg := Last(transcript.Summary{LastTool: tc.tool})
+		if seg.Text != tc.text || seg.Short != tc.short {
+			t.Errorf("Last(%q) = %q/%q, want %q/%q", tc.tool, seg.Text, seg.Short, tc.text, tc.short)
+		}
+	}
+}
+
+func TestCount(t *testing.T) {
+	if seg := Count(transcript.Summary{}); seg.Text != "" {
+		t.Errorf("no calls shows %q", seg.Text)
+	}
+	if seg := Count(transcript.Summary{ToolCalls: 14}); seg.Text != "⚒14" {
+		t.Errorf("Count = %q, want ⚒14", seg.Text)
+	}
+}
@@ -3,13 +3,20 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
 	"time"
 
+	"github.com/hergert/ccsl/internal/cache"
 	"github.com/hergert/ccsl/internal/config"
+	"github.com/hergert/ccsl/internal/daemon"
 	"github.com/hergert/ccsl/internal/herdr"
+	"github.com/hergert/ccsl/internal/ledger"
 	"github.com/hergert/ccsl/internal/palette"
 	"github.com/hergert/ccsl/internal/render"
 	"github.com/hergert/ccsl/internal/runner"
@@ -24,9 +31,18 @@ func main() {
 		case "doctor":
 			runDoctor()
 			return
+		case "report":
+			runReport(os.Args[2:])
+			return
 		case "--version":
 			fmt.Println("ccsl " + version)
 			return
+		case "__refresh":
+			runRefresh(os.Args[2:])
+			return
+		case "__supervise":
+			runSupervise(os.Args[2:])
+			return
 		}
 	}
 
@@ -40,24 +56,122 @@ func main() {
 		os.Exit(0)
 	}
 
-	var projectDir string
-	if ws, ok := ctxObj["workspace"].(map[string]any); ok {
-		if dir, ok := ws["project_dir"].(string); ok {
-			projectDir = dir
-		}
-	}
-
-	cfg := config.Load(projectDir)
+	cfg := config.Load(projectDir(ctxObj))
 	ctx, cancel := context.WithTimeout(context.Background(),
 		time.Duration(cfg.Limits.TotalBudgetMS)*time.Millisecond)
 	defer cancel()
 
+	if ledger.Enabled() {
+		var estimate func() (float64, bool)
+		if cfg.Plugin["cost"].Estimate != "never" {
+			estimate = func() (float64, bool) { return runner.EstimateCost(ctx, ctxObj, cfg) }
+		}
+		_ = ledger.Record(ctxObj, time.Now(), estimate)
+	}
+
 	segs := runner.Collect(ctx, ctxObj, raw, cfg)
 	maxLen := render.EffectiveMaxLen(cfg.UI.Truncate, os.Getenv("COLUMNS"))
-	fmt.Println(render.Line(cfg.UI.Template, segs, palette.From(cfg), maxLen))
+	fmt.Println(render.Status(cfg.UI, segs, palette.From(cfg), maxLen))
 	herdr.Report(ctxObj)
 }
 
+// Background refreshes get far more time than the interactive budget: that
+// is the point of moving them off the render path.
+const refreshTimeout = 30 * time.Second
+
+// runRefresh is the detached half of the segment cache: args are segment
+// id, cache key and the file holding the statusline JSON to replay.
+func runRefresh(args []string) {
+	if len(args) != 3 {
+		os.Exit(2)
+	}
+	id, key, input := args[0], args[1], args[2]
+	raw, err := os.ReadFile(input)
+	_ = os.Remove(input)
+	var ctxObj map[string]any
+	if err != nil || json.Unmarshal(raw, &ctxObj) != nil {
+		cache.Unlock(key)
+		os.Exit(1)
+	}
+
+	cfg := config.Load(projectDir(ctxObj))
+	ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+	defer cancel()
+	if runner.Refresh(ctx, id, key, ctxObj, raw, cfg) != nil {
+		os.Exit(1)
+	}
+}
+
+// runSupervise is the detached daemon supervisor: args are the socket,
+// the idle timeout in milliseconds, then the plugin's command and args.
+func runSupervise(args []string) {
+	if len(args) < 3 {
+		os.Exit(2)
+	}
+	idle, err := strconv.Atoi(args[1])
+	if err != nil {
+		os.Exit(2)
+	}
+	if daemon.Supervise(args[0], time.Duration(idle)*time.Millisecond, args[2], args[3:]) != nil {
+		os.Exit(1)
+	}
+}
+
+func projectDir(ctxObj map[string]any) string {
+	if ws, ok := ctxObj["workspace"].(map[string]any); ok {
+		if dir, ok := ws["project_dir"].(string); ok {
+			return dir
+		}
+	}
+	return ""
+}
+
+// runReport prints recorded spend grouped by day, project and model.
+func runReport(args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	days := fs.Int("days", 30, "how many days back to include")
+	_ = fs.Parse(args)
+
+	now := time.Now()
+	since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).
+		AddDate(0, 0, 1-*days)
+	sessions, err := ledger.Load(since)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ccsl report:", err)
+		os.Exit(1)
+	}
+	rows := ledger.Rows(sessions, since)
+	if len(rows) == 0 {
+		fmt.Printf("no sessions recorded in %s\n", ledger.Dir())
+		return
+	}
+
+	home, _ := os.UserHomeDir()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DAY\tPROJECT\tMODEL\tSESSIONS\tCOST\t")
+	var total float64
+	var estimated bool
+	for _, r := range rows {
+		project := r.ProjectDir
+		if home != "" && strings.HasPrefix(project, home) {
+			project = "~" + strings.TrimPrefix(project, home)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t\n", r.Day, project, r.Model, r.Sessions, usd(r.CostUSD, r.Estimated))
+		total += r.CostUSD
+		estimated = estimated || r.Estimated
+	}
+	fmt.Fprintf(w, "\t\t\t\t%s\t\n", usd(total, estimated))
+	_ = w.Flush()
+}
+
+// usd formats a report amount, marked ~ when part of it is estimated.
+func usd(v float64, estimated bool) string {
+	if estimated {
+		return fmt.Sprintf("~$%.2f", v)
+	}
+	return fmt.Sprintf("$%.2f", v)
+}
+
 type doctorInput struct {
 	Model struct {
 		DisplayName string `json:"display_name"`
@@ -129,7 +243,9 @@ func runDoctor() {
 	var ctxObj map[string]any
 	_ = json.Unmarshal(raw, &ctxObj)
 
+	// The sample rate limits must not land in the real burn-rate history.
 	cfg := config.Load()
+	cfg.DryRun = true
 	ctx, cancel := context.WithTimeout(context.Background(),
 		time.Duration(cfg.Limits.TotalBudgetMS)*time.Millisecond)
 	defer cancel()
@@ -138,12 +254,22 @@ func runDoctor() {
 	segs := runner.Collect(ctx, ctxObj, raw, cfg)
 	elapsed := time.Since(start)
 
-	line := render.Line(cfg.UI.Template, segs, palette.From(cfg), cfg.UI.Truncate)
+	pal := palette.From(cfg)
 
 	fmt.Printf("version:  %s\n", version)
-	fmt.Printf("template: %s\n", cfg.UI.Template)
+	for _, w := range append(cfg.Warnings, pal.Warnings()...) {
+		fmt.Printf("warning:  %s\n", w)
+	}
+	for _, tmpl := range cfg.UI.Templates() {
+		fmt.Printf("template: %s\n", tmpl)
+		if _, err := render.Parse(tmpl); err != nil {
+			fmt.Printf("error:    %v\n", err)
+		}
+	}
 	fmt.Printf("elapsed:  %dms\n", elapsed.Milliseconds())
-	fmt.Printf("output:   %s\n", line)
+	for _, line := range strings.Split(render.Status(cfg.UI, segs, pal, cfg.UI.Truncate), "\n") {
+		fmt.Printf("output:   %s\n", line)
+	}
 
 	customStatus, displayAgent := herdr.Status(ctxObj)
 	fmt.Printf("herdr:    %s · %s\n", displayAgent, customStatus)
@@ -6,15 +6,18 @@ import (
 	"testing"
 	"time"
 
+	"github.com/hergert/ccsl/internal/cache"
 	"github.com/hergert/ccsl/internal/config"
 	"github.com/hergert/ccsl/internal/palette"
 	"github.com/hergert/ccsl/internal/render"
 	"github.com/hergert/ccsl/internal/runner"
+	"github.com/hergert/ccsl/internal/types"
 )
 
 func isolatedConfig(t *testing.T) *config.Config {
 	t.Helper()
 	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
 	t.Setenv("HOME", t.TempDir())
 	return config.Load()
 }
@@ -45,6 +48,47 @@ func TestBasicFunctionality(t *testing.T) {
 	t.Logf("Generated line: %s", line)
 }
 
+func TestLinesCollectSegmentsFromEveryLine(t *testing.T) {
+	cfg := isolatedConfig(t)
+	cfg.UI.Lines = []string{"{model}", "{cwd}{lines?prefix= }"}
+	cfg.Theme.ANSI = false
+
+	input := []byte(`{
+		"model": {"display_name": "Test"},
+		"workspace": {"current_dir": "/tmp/proj"},
+		"cost": {"total_lines_added": 5, "total_lines_removed": 1}
+	}`)
+	var ctxObj map[string]any
+	_ = json.Unmarshal(input, &ctxObj)
+
+	segments := runner.Collect(context.Background(), ctxObj, input, cfg)
+	got := render.Lines(cfg.UI.Templates(), segments, palette.From(cfg), 0)
+	if got != "Test\nproj +5-1" {
+		t.Errorf("Lines = %q, want %q", got, "Test\nproj +5-1")
+	}
+}
+
+func TestCachedSegmentServedFromDisk(t *testing.T) {
+	cfg := isolatedConfig(t)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	cfg.UI.Template = "{slow}"
+	// The command would fail; a fresh cache entry means it never runs.
+	cfg.Plugin["slow"] = config.PluginConfig{Type: "exec", Command: "false", CacheTTLMS: 60000}
+
+	input := []byte(`{"workspace": {"current_dir": "/tmp/proj"}}`)
+	var ctxObj map[string]any
+	_ = json.Unmarshal(input, &ctxObj)
+	key := cache.Key("slow", nil, ctxObj)
+	if err := cache.Store(key, types.Segment{Text: "cached"}); err != nil {
+		t.Fatal(err)
+	}
+
+	segments := runner.Collect(context.Background(), ctxObj, input, cfg)
+	if len(segments) != 1 || segments[0].Text != "cached" {
+		t.Errorf("segments = %+v, want cached value", segments)
+	}
+}
+
 func TestConfigLoading(t *testing.T) {
 	cfg := isolatedConfig(t)
 
@@ -0,0 +1,166 @@
+// Package cache keeps the last rendered value of slow segments on disk so
+// they survive between ccsl invocations. Entries are served while fresh,
+// served stale while a detached ccsl process refreshes them, and written
+// atomically so concurrent Claude sessions never read a torn file.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hergert/ccsl/internal/detach"
+	"github.com/hergert/ccsl/internal/gitrepo"
+	"github.com/hergert/ccsl/internal/types"
+	"github.com/hergert/ccsl/internal/xdg"
+)
+
+const (
+	// Stale entries older than this are dropped rather than shown.
+	maxStale = 24 * time.Hour
+	// A refresh lock older than this belongs to a crashed refresher.
+	lockTimeout = time.Minute
+)
+
+type Entry struct {
+	Segment  types.Segment `json:"segment"`
+	StoredAt time.Time     `json:"stored_at"`
+}
+
+// Fresh reports whether the entry is younger than ttl.
+func (e Entry) Fresh(ttl time.Duration) bool {
+	return time.Since(e.StoredAt) < ttl
+}
+
+// Key derives the cache key for segment id from the statusline JSON. inputs
+// names the parts that invalidate it: "cwd" (default), "session_id",
+// "git_head". Unknown inputs are ignored.
+func Key(id string, inputs []string, raw map[string]any) string {
+	if len(inputs) == 0 {
+		inputs = []string{"cwd"}
+	}
+	h := sha256.New()
+	h.Write([]byte(id))
+	for _, in := range inputs {
+		h.Write([]byte{0})
+		h.Write([]byte(in + "=" + keyInput(in, raw)))
+	}
+	return sanitize(id) + "-" + hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+func keyInput(name string, raw map[string]any) string {
+	switch name {
+	case "cwd":
+		return currentDir(raw)
+	case "session_id":
+		s, _ := raw["session_id"].(string)
+		return s
+	case "git_head":
+		repo, err := gitrepo.Open(currentDir(raw))
+		if err != nil {
+			return ""
+		}
+		defer func() { _ = repo.Close() }()
+		branch, oid, _ := repo.Head()
+		return branch + "@" + oid.String()
+	}
+	return ""
+}
+
+func currentDir(raw map[string]any) string {
+	if ws, ok := raw["workspace"].(map[string]any); ok {
+		if dir, ok := ws["current_dir"].(string); ok && dir != "" {
+			return dir
+		}
+	}
+	dir, _ := os.Getwd()
+	return dir
+}
+
+// Segment ids may contain ':' and '.'; keep file names boring.
+func sanitize(id string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, id)
+}
+
+// Load returns the entry stored under key, if any and not too stale.
+func Load(key string) (Entry, bool) {
+	data, err := os.ReadFile(filepath.Join(xdg.CacheDir(), key+".json"))
+	if err != nil {
+		return Entry{}, false
+	}
+	var e Entry
+	if json.Unmarshal(data, &e) != nil || time.Since(e.StoredAt) > maxStale {
+		return Entry{}, false
+	}
+	return e, true
+}
+
+// Store writes seg under key via rename, so readers see old or new, never
+// a partial file.
+func Store(key string, seg types.Segment) error {
+	data, err := json.Marshal(Entry{Segment: seg, StoredAt: time.Now()})
+	if err != nil {
+		return err
+	}
+	return xdg.WriteFileAtomic(filepath.Join(xdg.CacheDir(), key+".json"), data)
+}
+
+// TryLock claims the refresh of key so that several sessions sharing an
+// entry start only one refresher. Locks from crashed refreshers expire.
+func TryLock(key string) bool {
+	path := filepath.Join(xdg.CacheDir(), key+".lock")
+	if err := os.MkdirAll(xdg.CacheDir(), 0o700); err != nil {
+		return false
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			_ = f.Close()
+			return true
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return false
+		}
+		fi, err := os.Stat(path)
+		if err != nil || time.Since(fi.ModTime()) < lockTimeout {
+			return false
+		}
+		_ = os.Remove(path)
+	}
+	return false
+}
+
+// Revalidate refreshes the entry for segment id in a detached
+// `ccsl __refresh` process, unless another session is already doing so.
+// The statusline JSON is handed over in a file since ccsl exits first.
+func Revalidate(id, key string, claudeJSON []byte) {
+	if !TryLock(key) {
+		return
+	}
+	exe, err := os.Executable()
+	if err == nil {
+		input := filepath.Join(xdg.CacheDir(), key+".input")
+		if err = os.WriteFile(input, claudeJSON, 0o600); err == nil {
+			err = detach.Start(exec.Command(exe, "__refresh", id, key, input))
+		}
+	}
+	if err != nil {
+		Unlock(key)
+	}
+}
+
+// Unlock releases a refresh claimed with TryLock.
+func Unlock(key string) {
+	_ = os.Remove(filepath.Join(xdg.CacheDir(), key+".lock"))
+}
@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hergert/ccsl/internal/types"
+	"github.com/hergert/ccsl/internal/xdg"
+)
+
+func TestStoreLoadRoundTrip(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	if _, ok := Load("k8s-abc"); ok {
+		t.Fatal("expected miss on empty cache")
+	}
+	seg := types.Segment{Text: "prod/default", Style: "dim", Priority: 35,
+		Parts: []types.Segment{{ID: "ns", Text: "default"}}}
+	if err := Store("k8s-abc", seg); err != nil {
+		t.Fatal(err)
+	}
+	e, ok := Load("k8s-abc")
+	if !ok || !reflect.DeepEqual(e.Segment, seg) {
+		t.Fatalf("Load = %+v ok=%v, want %+v", e, ok, seg)
+	}
+	if !e.Fresh(time.Minute) {
+		t.Error("just-stored entry should be fresh")
+	}
+	if e.Fresh(0) {
+		t.Error("entry should be stale with a zero TTL")
+	}
+}
+
+func TestKeyInputs(t *testing.T) {
+	raw := func(dir, session string) map[string]any {
+		return map[string]any{
+			"session_id": session,
+			"workspace":  map[string]any{"current_dir": dir},
+		}
+	}
+
+	if Key("gh", nil, raw("/a", "s1")) != Key("gh", nil, raw("/a", "s2")) {
+		t.Error("default key should ignore session_id")
+	}
+	if Key("gh", nil, raw("/a", "s1")) == Key("gh", nil, raw("/b", "s1")) {
+		t.Error("default key should vary with cwd")
+	}
+	bySession := []string{"session_id"}
+	if Key("gh", bySession, raw("/a", "s1")) == Key("gh", bySession, raw("/a", "s2")) {
+		t.Error("session_id key should vary with session")
+	}
+	if Key("gh", nil, raw("/a", "s1")) == Key("kube", nil, raw("/a", "s1")) {
+		t.Error("key should vary with segment id")
+	}
+	if k := Key("plug:sub", nil, raw("/a", "")); filepath.Base(k) != k {
+		t.Errorf("key %q is not a plain file name", k)
+	}
+}
+
+func TestTryLock(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	if !TryLock("k") {
+		t.Fatal("first TryLock should succeed")
+	}
+	if TryLock("k") {
+		t.Fatal("second TryLock should fail while held")
+	}
+	Unlock("k")
+	if !TryLock("k") {
+		t.Fatal("TryLock should succeed after Unlock")
+	}
+
+	old := time.Now().Add(-2 * lockTimeout)
+	if err := os.Chtimes(filepath.Join(xdg.CacheDir(), "k.lock"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if !TryLock("k") {
+		t.Error("TryLock should reclaim a lock left by a crashed refresher")
+	}
+}
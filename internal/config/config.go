@@ -1,8 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/BurntSushi/toml"
@@ -14,15 +16,50 @@ type Config struct {
 	Plugins PluginsConfig           `toml:"plugins"`
 	Plugin  map[string]PluginConfig `toml:"plugin"`
 	Limits  LimitsConfig            `toml:"limits"`
+	Budget  BudgetConfig            `toml:"budget"`
+	Pricing map[string]Price        `toml:"pricing"` // model id -> rates, over the built-in table
+
+	// Problems found while loading, for `ccsl doctor`.
+	Warnings []string `toml:"-"`
+	// DryRun renders without recording ctx and ratelimit history, so the
+	// sample input of `ccsl doctor` leaves the real state alone.
+	DryRun bool `toml:"-"`
 }
 
 type UIConfig struct {
-	Template string `toml:"template"`
-	Truncate int    `toml:"truncate"`
+	Template string   `toml:"template"`
+	Lines    []string `toml:"lines"` // one template per status line; overrides template
+	Truncate int      `toml:"truncate"`
+
+	// "powerline" lays the template's segments out as colored blocks and
+	// ignores its literal text; "" renders the template as written.
+	Mode      string `toml:"mode"`
+	Separator string `toml:"separator"` // powerline glyph, default U+E0B0
+	ASCII     bool   `toml:"ascii"`     // powerline with ">" instead of the glyph
+}
+
+// Templates returns the per-line templates to render, in order.
+func (u UIConfig) Templates() []string {
+	if len(u.Lines) > 0 {
+		return u.Lines
+	}
+	return []string{u.Template}
 }
 
+// ThemeConfig picks a built-in theme and adjusts it. Styles are space
+// separated words: attributes (bold, dim, italic, underline), a color, and
+// "on:<color>" for the background. Colors are "#rrggbb", an index 0-255, a
+// basic name (red, bright-blue, ...) or a name from colors.
 type ThemeConfig struct {
-	ANSI bool `toml:"ansi"`
+	ANSI     bool              `toml:"ansi"`
+	Name     string            `toml:"name"`     // dark (default), light, solarized, high-contrast
+	Depth    string            `toml:"depth"`    // truecolor, 256 or 16; detected from COLORTERM/TERM if unset
+	Colors   map[string]string `toml:"colors"`   // named colors for use in roles and segments
+	Roles    map[string]string `toml:"roles"`    // bold, dim, warn, error, ok, accent -> style
+	Segments map[string]string `toml:"segments"` // segment id -> base style, replacing the segment's own
+	Blocks   []string          `toml:"blocks"`   // powerline background colors, cycled
+
+	Hyperlinks bool `toml:"hyperlinks"` // OSC 8 links on pr, git, cwd, gcp, cf and aws
 }
 
 type PluginsConfig struct {
@@ -30,11 +67,75 @@ type PluginsConfig struct {
 }
 
 type PluginConfig struct {
-	Type      string   `toml:"type"`    // builtin | exec
-	Command   string   `toml:"command"` // for exec type
-	Args      []string `toml:"args"`
-	TimeoutMS int      `toml:"timeout_ms"`
-	Untracked bool     `toml:"untracked"` // for git: include untracked files
+	Type       string   `toml:"type"`    // builtin | exec | daemon
+	Command    string   `toml:"command"` // for exec and daemon, with ~ and $VAR expanded
+	Args       []string `toml:"args"`    // expanded like command
+	TimeoutMS  int      `toml:"timeout_ms"`
+	Truncate   string   `toml:"truncate"`   // for all: "drop", "shorten" or "never" when the line is too long
+	Untracked  bool     `toml:"untracked"`  // for git: include untracked files
+	Trend      string   `toml:"trend"`      // for ctx: "delta" (+N per turn) or "turns" (~Nt until red)
+	Estimate   string   `toml:"estimate"`   // for cost: "auto" (default, without total_cost_usd), "always" or "never"
+	Production string   `toml:"production"` // for k8s: regexp of production context names, flagged with ⚠
+
+	// for ctx: yellow/red cut-offs for 1M ("large") and 200k windows
+	Large    Thresholds `toml:"large"`
+	Standard Thresholds `toml:"standard"`
+
+	// for ratelimit: warn/error/countdown for both windows, then per window
+	Thresholds
+	FiveHour Thresholds `toml:"five_hour"`
+	SevenDay Thresholds `toml:"seven_day"`
+
+	// for ratelimit: project when a window runs out from its recent burn rate
+	Burn          *bool   `toml:"burn"`            // default true
+	BurnWindowMin int     `toml:"burn_window_min"` // sample history considered, default 30
+	BurnFloorPct  float64 `toml:"burn_floor_pct"`  // no projection below this usage, default 50
+
+	// for exec and daemon: env adds (expanded) variables to the inherited
+	// environment, env_clear inherits only the names in env_allow; cwd
+	// defaults to workspace.current_dir for exec plugins
+	Env      map[string]string `toml:"env"`
+	EnvClear bool              `toml:"env_clear"`
+	EnvAllow []string          `toml:"env_allow"`
+	Cwd      string            `toml:"cwd"`
+
+	// for daemon: a persistent plugin answering JSON-RPC over a Unix socket,
+	// launched from command, or already listening on socket
+	Socket        string `toml:"socket"`
+	IdleTimeoutMS int    `toml:"idle_timeout_ms"` // stop a launched daemon unused this long, default 10 min
+
+	// Opt-in on-disk cache: serve the last value for cache_ttl_ms, then keep
+	// serving it while a background process refreshes. cache_key lists what
+	// invalidates an entry: "cwd" (default), "session_id", "git_head".
+	CacheTTLMS int      `toml:"cache_ttl_ms"`
+	CacheKey   []string `toml:"cache_key"`
+}
+
+// BudgetConfig sets USD limits that color the cost segment: yellow from
+// warn_at of any limit, red with a "!" once one is exceeded. Zero disables
+// a limit. Day and project totals come from the local session ledger.
+type BudgetConfig struct {
+	SessionUSD float64 `toml:"session_usd"`
+	DailyUSD   float64 `toml:"daily_usd"`
+	MonthlyUSD float64 `toml:"monthly_usd"`
+	ProjectUSD float64 `toml:"project_usd"` // per project, per day
+	WarnAt     float64 `toml:"warn_at"`     // fraction of a limit, default 0.8
+}
+
+// Enabled reports whether any limit is set.
+func (b BudgetConfig) Enabled() bool {
+	return b.SessionUSD > 0 || b.DailyUSD > 0 || b.MonthlyUSD > 0 || b.ProjectUSD > 0
+}
+
+// Price is what a model costs in USD per million tokens. Fields are nil
+// when unset, so an explicit 0 (a free self-hosted model) is kept: unset
+// rates of a listed model keep the listed ones, and unset cache rates
+// follow the input rate, reads at 0.1x and writes at 1.25x.
+type Price struct {
+	Input      *float64 `toml:"input"`
+	Output     *float64 `toml:"output"`
+	CacheRead  *float64 `toml:"cache_read"`
+	CacheWrite *float64 `toml:"cache_write"`
 }
 
 type LimitsConfig struct {
@@ -70,17 +171,17 @@ func Load(projectDir ...string) *Config {
 			continue
 		}
 		if _, err := toml.Decode(string(data), cfg); err != nil {
+			cfg.Warnings = append(cfg.Warnings, fmt.Sprintf("%s: %v", path, err))
 			continue
 		}
 		break
 	}
 
 	// Env overrides
-	if os.Getenv("CCSL_ANSI") == "0" {
-		cfg.Theme.ANSI = false
-	}
+	cfg.Theme.ANSI = ColorEnv(cfg.Theme.ANSI)
 	if v := os.Getenv("CCSL_TEMPLATE"); v != "" {
 		cfg.UI.Template = v
+		cfg.UI.Lines = nil
 	}
 	if v, ok := os.LookupEnv("CCSL_ORDER"); ok {
 		if v == "" {
@@ -97,9 +198,68 @@ func Load(projectDir ...string) *Config {
 		}
 	}
 
+	switch cfg.UI.Mode {
+	case "", "template", "powerline":
+	default:
+		cfg.Warnings = append(cfg.Warnings, fmt.Sprintf("ui.mode: unknown mode %q (want template or powerline)", cfg.UI.Mode))
+		cfg.UI.Mode = ""
+	}
+	for id, p := range cfg.Plugin {
+		switch p.Truncate {
+		case "", "drop", "shorten", "never":
+		default:
+			cfg.Warnings = append(cfg.Warnings, fmt.Sprintf("plugin.%s.truncate: unknown policy %q (want drop, shorten or never)", id, p.Truncate))
+			p.Truncate = ""
+			cfg.Plugin[id] = p
+		}
+	}
+	if p, ok := cfg.Plugin["cost"]; ok {
+		switch p.Estimate {
+		case "", "auto", "always", "never":
+		default:
+			cfg.Warnings = append(cfg.Warnings, fmt.Sprintf("plugin.cost.estimate: unknown mode %q (want auto, always or never)", p.Estimate))
+			p.Estimate = ""
+			cfg.Plugin["cost"] = p
+		}
+	}
+	if p, ok := cfg.Plugin["k8s"]; ok && p.Production != "" {
+		if _, err := regexp.Compile(p.Production); err != nil {
+			cfg.Warnings = append(cfg.Warnings, fmt.Sprintf("plugin.k8s.production: %v; using the default", err))
+			p.Production = ""
+			cfg.Plugin["k8s"] = p
+		}
+	}
+	negative := func(v *float64) bool { return v != nil && *v < 0 }
+	for model, p := range cfg.Pricing {
+		if negative(p.Input) || negative(p.Output) || negative(p.CacheRead) || negative(p.CacheWrite) {
+			cfg.Warnings = append(cfg.Warnings, fmt.Sprintf("pricing.%s: negative price; using the built-in rates", model))
+			delete(cfg.Pricing, model)
+		}
+	}
+	cfg.Warnings = append(cfg.Warnings, validateThresholds(cfg)...)
 	return cfg
 }
 
+// ColorEnv applies the environment's color conventions to the configured
+// [theme] ansi setting: NO_COLOR and TERM=dumb turn color off, FORCE_COLOR
+// turns it on (or off when "0"/"false"), and CCSL_ANSI=0 wins over all.
+func ColorEnv(ansi bool) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		ansi = false
+	}
+	switch v := os.Getenv("FORCE_COLOR"); v {
+	case "":
+	case "0", "false":
+		ansi = false
+	default:
+		ansi = true
+	}
+	if os.Getenv("CCSL_ANSI") == "0" {
+		ansi = false
+	}
+	return ansi
+}
+
 func defaultConfig() *Config {
 	return &Config{
 		UI: UIConfig{
@@ -121,5 +281,8 @@ func defaultConfig() *Config {
 			PerPluginTimeoutMS: 100,
 			TotalBudgetMS:      200,
 		},
+		Budget: BudgetConfig{
+			WarnAt: 0.8,
+		},
 	}
 }
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func load(t *testing.T, toml string) *Config {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", t.TempDir())
+	if err := os.MkdirAll(filepath.Join(dir, "ccsl"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ccsl", "config.toml"), []byte(toml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return Load()
+}
+
+func TestThresholdDefaults(t *testing.T) {
+	cfg := load(t, "")
+	if got := cfg.Plugin["ctx"].CtxLevels(true); got != DefaultCtxLarge {
+		t.Errorf("ctx large = %+v, want %+v", got, DefaultCtxLarge)
+	}
+	if got := cfg.Plugin["ctx"].CtxLevels(false); got != DefaultCtxStandard {
+		t.Errorf("ctx standard = %+v, want %+v", got, DefaultCtxStandard)
+	}
+	if got := cfg.Plugin["ratelimit"].RateLimitLevels("five_hour"); got != DefaultRateLimit {
+		t.Errorf("ratelimit = %+v, want %+v", got, DefaultRateLimit)
+	}
+	if len(cfg.Warnings) != 0 {
+		t.Errorf("Warnings = %v", cfg.Warnings)
+	}
+}
+
+func TestThresholdOverrides(t *testing.T) {
+	cfg := load(t, `
+[plugin.ctx]
+large = { warn = 20, error = 40 }
+standard = { error = 95 }
+
+[plugin.ratelimit]
+warn = 60
+countdown = 0
+seven_day = { warn = 80, error = 95, countdown = 50 }
+`)
+	ctx := cfg.Plugin["ctx"]
+	if got, want := ctx.CtxLevels(true), (Levels{Warn: 20, Error: 40}); got != want {
+		t.Errorf("ctx large = %+v, want %+v", got, want)
+	}
+	if got, want := ctx.CtxLevels(false), (Levels{Warn: 50, Error: 95}); got != want {
+		t.Errorf("ctx standard = %+v, want %+v", got, want)
+	}
+	rl := cfg.Plugin["ratelimit"]
+	if got, want := rl.RateLimitLevels("five_hour"), (Levels{Warn: 60, Error: 90, Countdown: 0}); got != want {
+		t.Errorf("five_hour = %+v, want %+v", got, want)
+	}
+	if got, want := rl.RateLimitLevels("seven_day"), (Levels{Warn: 80, Error: 95, Countdown: 50}); got != want {
+		t.Errorf("seven_day = %+v, want %+v", got, want)
+	}
+}
+
+func TestThresholdValidation(t *testing.T) {
+	cfg := load(t, `
+[plugin.ctx]
+standard = { warn = 90 }
+
+[plugin.ratelimit]
+warn = 50
+five_hour = { countdown = 150 }
+`)
+	if got := cfg.Plugin["ctx"].CtxLevels(false); got != DefaultCtxStandard {
+		t.Errorf("invalid ctx standard kept: %+v", got)
+	}
+	rl := cfg.Plugin["ratelimit"]
+	if got := rl.RateLimitLevels("five_hour"); got.Countdown != 70 || got.Warn != 50 {
+		t.Errorf("five_hour = %+v, want shared warn and default countdown", got)
+	}
+	if len(cfg.Warnings) != 2 ||
+		!strings.Contains(cfg.Warnings[0], "plugin.ctx.standard") ||
+		!strings.Contains(cfg.Warnings[1], "plugin.ratelimit.five_hour") {
+		t.Errorf("Warnings = %q", cfg.Warnings)
+	}
+}
+
+func TestDecodeErrorWarns(t *testing.T) {
+	cfg := load(t, "[ui\n")
+	if len(cfg.Warnings) != 1 || !strings.Contains(cfg.Warnings[0], "config.toml") {
+		t.Errorf("Warnings = %q", cfg.Warnings)
+	}
+}
+
+func TestTruncatePolicyValidation(t *testing.T) {
+	cfg := load(t, `
+[plugin.model]
+truncate = "shorten"
+
+[plugin.cwd]
+truncate = "hide"
+`)
+	if got := cfg.Plugin["model"].Truncate; got != "shorten" {
+		t.Errorf("model truncate = %q, want shorten", got)
+	}
+	if got := cfg.Plugin["cwd"].Truncate; got != "" {
+		t.Errorf("invalid cwd truncate kept: %q", got)
+	}
+	if len(cfg.Warnings) != 1 || !strings.Contains(cfg.Warnings[0], "plugin.cwd.truncate") {
+		t.Errorf("Warnings = %q", cfg.Warnings)
+	}
+}
+
+func TestPricingValidation(t *testing.T) {
+	cfg := load(t, `
+[plugin.cost]
+estimate = "sometimes"
+
+[pricing."claude-sonnet-4-5"]
+input = 2.4
+output = 12
+
+[pricing."local"]
+input = 0
+
+[pricing."claude-opus"]
+input = -5
+`)
+	if got := cfg.Plugin["cost"].Estimate; got != "" {
+		t.Errorf("invalid estimate mode kept: %q", got)
+	}
+	if got := cfg.Pricing["claude-sonnet-4-5"]; got.Input == nil || *got.Input != 2.4 || got.Output == nil || *got.Output != 12 || got.CacheRead != nil {
+		t.Errorf("sonnet price = %+v", got)
+	}
+	if got := cfg.Pricing["local"]; got.Input == nil || *got.Input != 0 {
+		t.Errorf("zero price read as unset: %+v", got)
+	}
+	if _, ok := cfg.Pricing["claude-opus"]; ok {
+		t.Error("negative price kept")
+	}
+	if len(cfg.Warnings) != 2 {
+		t.Errorf("Warnings = %q", cfg.Warnings)
+	}
+}
+
+func TestProductionPatternValidation(t *testing.T) {
+	cfg := load(t, `
+[plugin.k8s]
+production = "prod("
+`)
+	if got := cfg.Plugin["k8s"].Production; got != "" {
+		t.Errorf("invalid production pattern kept: %q", got)
+	}
+	if len(cfg.Warnings) != 1 || !strings.Contains(cfg.Warnings[0], "plugin.k8s.production") {
+		t.Errorf("Warnings = %q", cfg.Warnings)
+	}
+}
+
+func TestColorEnv(t *testing.T) {
+	cases := []struct {
+		name string
+		env  map[string]string
+		ansi bool
+		want bool
+	}{
+		{"configured on", nil, true, true},
+		{"configured off", nil, false, false},
+		{"NO_COLOR", map[string]string{"NO_COLOR": "1"}, true, false},
+		{"empty NO_COLOR ignored", map[string]string{"NO_COLOR": ""}, true, true},
+		{"TERM=dumb", map[string]string{"TERM": "dumb"}, true, false},
+		{"FORCE_COLOR beats config", map[string]string{"FORCE_COLOR": "1"}, false, true},
+		{"FORCE_COLOR beats NO_COLOR", map[string]string{"FORCE_COLOR": "3", "NO_COLOR": "1"}, true, true},
+		{"FORCE_COLOR=0", map[string]string{"FORCE_COLOR": "0"}, true, false},
+		{"CCSL_ANSI=0 wins", map[string]string{"FORCE_COLOR": "1", "CCSL_ANSI": "0"}, true, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, k := range []string{"NO_COLOR", "FORCE_COLOR", "CCSL_ANSI"} {
+				t.Setenv(k, "")
+			}
+			t.Setenv("TERM", "xterm-256color")
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			if got := ColorEnv(tc.ansi); got != tc.want {
+				t.Errorf("ColorEnv(%v) = %v, want %v", tc.ansi, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package config
+
+import "fmt"
+
+// Levels are resolved severity cut-offs in percent of a window used: yellow
+// from Warn, red from Error. Countdown is the usage from which ratelimit
+// shows the time until its window resets.
+type Levels struct {
+	Warn      float64
+	Error     float64
+	Countdown float64
+}
+
+// Thresholds override Levels from config; unset fields keep the default.
+type Thresholds struct {
+	Warn      *float64 `toml:"warn"`
+	Error     *float64 `toml:"error"`
+	Countdown *float64 `toml:"countdown"`
+}
+
+// Anthropic's MRCR v2 benchmark: Opus drops from 93% (256K) to 76% (1M).
+// User reports (github.com/anthropics/claude-code/issues/35296, #34685):
+//
+//	20-40% of 1M: degradation starts, wrong approaches
+//	40-60%: fabrications, confident false conclusions
+//	60%+:   prior facts inaccessible, irrecoverable loops
+//
+// Effective useful context is ~200-400K tokens regardless of window size.
+// 1M thresholds are therefore more aggressive, not less: 30% of 1M is 300K
+// tokens (degradation onset), 60% is 600K (reliability lost).
+var (
+	DefaultCtxLarge    = Levels{Warn: 30, Error: 60}
+	DefaultCtxStandard = Levels{Warn: 50, Error: 85}
+	DefaultRateLimit   = Levels{Warn: 70, Error: 90, Countdown: 70}
+)
+
+// Over returns l with the fields set in t replaced.
+func (t Thresholds) Over(l Levels) Levels {
+	if t.Warn != nil {
+		l.Warn = *t.Warn
+	}
+	if t.Error != nil {
+		l.Error = *t.Error
+	}
+	if t.Countdown != nil {
+		l.Countdown = *t.Countdown
+	}
+	return l
+}
+
+// CtxLevels resolves the ctx cut-offs for a 1M (large) or 200k window.
+func (p PluginConfig) CtxLevels(large bool) Levels {
+	if large {
+		return p.Large.Over(DefaultCtxLarge)
+	}
+	return p.Standard.Over(DefaultCtxStandard)
+}
+
+// RateLimitLevels resolves the ratelimit cut-offs for "five_hour" or
+// "seven_day": the per-window table over the shared one over the defaults.
+func (p PluginConfig) RateLimitLevels(window string) Levels {
+	base := p.Thresholds.Over(DefaultRateLimit)
+	switch window {
+	case "five_hour":
+		return p.FiveHour.Over(base)
+	case "seven_day":
+		return p.SevenDay.Over(base)
+	}
+	return base
+}
+
+func (l Levels) validate() error {
+	for _, v := range []float64{l.Warn, l.Error, l.Countdown} {
+		if v < 0 || v > 100 {
+			return fmt.Errorf("%g is outside 0-100", v)
+		}
+	}
+	if l.Warn >= l.Error {
+		return fmt.Errorf("warn (%g) must be below error (%g)", l.Warn, l.Error)
+	}
+	return nil
+}
+
+// validateThresholds resets threshold tables that resolve to nonsense, so
+// the segment keeps its defaults, and says why.
+func validateThresholds(cfg *Config) []string {
+	var warnings []string
+	check := func(name string, t *Thresholds, l Levels) {
+		if err := l.validate(); err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %v; using defaults", name, err))
+			*t = Thresholds{}
+		}
+	}
+
+	if p, ok := cfg.Plugin["ctx"]; ok {
+		check("plugin.ctx.large", &p.Large, p.CtxLevels(true))
+		check("plugin.ctx.standard", &p.Standard, p.CtxLevels(false))
+		cfg.Plugin["ctx"] = p
+	}
+	if p, ok := cfg.Plugin["ratelimit"]; ok {
+		check("plugin.ratelimit", &p.Thresholds, p.RateLimitLevels(""))
+		check("plugin.ratelimit.five_hour", &p.FiveHour, p.RateLimitLevels("five_hour"))
+		check("plugin.ratelimit.seven_day", &p.SevenDay, p.RateLimitLevels("seven_day"))
+		cfg.Plugin["ratelimit"] = p
+	}
+	return warnings
+}
@@ -0,0 +1,129 @@
+// Package daemon keeps exec plugins running between refreshes, sparing each
+// one the fork/exec and interpreter startup. A detached supervisor
+// (`ccsl __supervise`) listens on a Unix socket and runs the plugin with
+// its stdin and stdout as a newline-delimited JSON-RPC 2.0 channel. It
+// restarts the plugin when it crashes and shuts down once unused for a
+// while; ccsl only ever talks to the socket.
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hergert/ccsl/internal/detach"
+	"github.com/hergert/ccsl/internal/xdg"
+)
+
+// DefaultIdle is how long a launched daemon lives without requests.
+const DefaultIdle = 10 * time.Minute
+
+// ErrNotRunning means nothing is listening on the socket.
+var ErrNotRunning = errors.New("daemon: not running")
+
+type request struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      int64  `json:"id,omitempty"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int64           `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("daemon: %s (%d)", e.Message, e.Code)
+}
+
+// RenderParams are the params of a "render" call: the segment id the
+// plugin is configured as, the statusline JSON, and the columns the status
+// line is cut to (0 for none).
+type RenderParams struct {
+	Segment    string          `json:"segment"`
+	Statusline json.RawMessage `json:"statusline"`
+	Columns    int             `json:"columns,omitempty"`
+}
+
+// SocketPath is where the supervisor for the daemon identified by key (its
+// command line and launch settings) listens: one daemon per distinct key,
+// shared by every Claude session.
+func SocketPath(key ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(key, "\x00")))
+	return filepath.Join(xdg.RuntimeDir(), "daemon-"+hex.EncodeToString(sum[:8])+".sock")
+}
+
+// Call sends a render request to the daemon on sock and returns the call's
+// result, within ctx's deadline. The statusline goes only to a socket in a
+// directory private to this user: in a shared one, anyone could have put
+// their own listener there.
+func Call(ctx context.Context, sock string, params RenderParams) (json.RawMessage, error) {
+	if err := xdg.CheckPrivate(filepath.Dir(sock)); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %v", ErrNotRunning, err)
+		}
+		return nil, err
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", sock)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotRunning, err)
+	}
+	defer func() { _ = conn.Close() }()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	req := request{JSONRPC: "2.0", ID: 1, Method: "render", Params: params}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	var resp response
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	return resp.Result, nil
+}
+
+// Launch starts a detached supervisor serving plugin on sock. The plugin's
+// environment and directory are the supervisor's, so it passes them on to
+// every restart.
+func Launch(sock string, idle time.Duration, plugin *exec.Cmd) error {
+	if plugin.Err != nil {
+		return plugin.Err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	argv := append([]string{"__supervise", sock, strconv.FormatInt(idle.Milliseconds(), 10), plugin.Path}, plugin.Args[1:]...)
+	cmd := exec.Command(exe, argv...)
+	cmd.Env, cmd.Dir = plugin.Env, plugin.Dir
+	return detach.Start(cmd)
+}
@@ -0,0 +1,193 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hergert/ccsl/internal/xdg"
+)
+
+// The test binary doubles as the plugin: with CCSL_TEST_DAEMON set it
+// answers render calls with its pid, and exits without answering when the
+// statusline asks it to crash.
+func TestMain(m *testing.M) {
+	if os.Getenv("CCSL_TEST_DAEMON") != "" {
+		servePlugin()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func servePlugin() {
+	sc := bufio.NewScanner(os.Stdin)
+	for sc.Scan() {
+		var req struct {
+			ID     int64        `json:"id"`
+			Method string       `json:"method"`
+			Params RenderParams `json:"params"`
+		}
+		if json.Unmarshal(sc.Bytes(), &req) != nil {
+			continue
+		}
+		switch {
+		case req.Method == "shutdown":
+			return
+		case string(req.Params.Statusline) == `{"crash":true}`:
+			os.Exit(1)
+		}
+		result, _ := json.Marshal(strconv.Itoa(os.Getpid()))
+		data, _ := json.Marshal(response{JSONRPC: "2.0", ID: req.ID, Result: result})
+		_, _ = os.Stdout.Write(append(data, '\n'))
+	}
+}
+
+// start runs a supervisor for the test plugin on a fresh socket and waits
+// for it to listen; the returned channel yields Supervise's error.
+func start(t *testing.T, idle time.Duration) (string, <-chan error) {
+	t.Helper()
+	t.Setenv("CCSL_TEST_DAEMON", "1")
+	dir := filepath.Join(t.TempDir(), "run")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	sock := filepath.Join(dir, "d.sock")
+	done := make(chan error, 1)
+	go func() { done <- Supervise(sock, idle, os.Args[0], nil) }()
+	for i := 0; i < 200; i++ {
+		if _, err := os.Stat(sock); err == nil {
+			return sock, done
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("supervisor never listened")
+	return "", nil
+}
+
+func render(t *testing.T, sock, statusline string) (string, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	res, err := Call(ctx, sock, RenderParams{Segment: "test", Statusline: []byte(statusline)})
+	if err != nil {
+		return "", err
+	}
+	var pid string
+	if err := json.Unmarshal(res, &pid); err != nil {
+		t.Fatalf("result %s: %v", res, err)
+	}
+	return pid, nil
+}
+
+func TestCallReusesPlugin(t *testing.T) {
+	sock, _ := start(t, time.Minute)
+	first, err := render(t, sock, `{}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := render(t, sock, `{}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Errorf("plugin pid changed from %s to %s between calls", first, second)
+	}
+}
+
+func TestRestartAfterCrash(t *testing.T) {
+	sock, _ := start(t, time.Minute)
+	before, err := render(t, sock, `{}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := render(t, sock, `{"crash":true}`); err == nil {
+		t.Fatal("crashing call succeeded")
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		after, err := render(t, sock, `{}`)
+		if err == nil {
+			if after == before {
+				t.Errorf("plugin pid %s survived its crash", after)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("plugin never restarted: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestIdleShutdown(t *testing.T) {
+	sock, done := start(t, 100*time.Millisecond)
+	if _, err := render(t, sock, `{}`); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("supervisor outlived its idle timeout")
+	}
+	if _, err := os.Stat(sock); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("socket left behind: %v", err)
+	}
+	if _, err := render(t, sock, `{}`); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Call after shutdown = %v, want ErrNotRunning", err)
+	}
+}
+
+func TestSocketPath(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	a := SocketPath("ccsl-k8s", "--fast")
+	if filepath.Dir(a) != "/run/user/1000/ccsl" {
+		t.Errorf("SocketPath = %s, want it under $XDG_RUNTIME_DIR/ccsl", a)
+	}
+	if a == SocketPath("ccsl-k8s") || a == SocketPath("ccsl-k8s --fast") {
+		t.Error("different command lines share a socket")
+	}
+	if a != SocketPath("ccsl-k8s", "--fast") {
+		t.Error("SocketPath is not stable")
+	}
+}
+
+func TestRefusesSharedDir(t *testing.T) {
+	private := t.TempDir()
+	shared := filepath.Join(private, "shared")
+	if err := os.Mkdir(shared, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(shared, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(private, "link")
+	if err := os.Symlink(private, link); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range []string{shared, link} {
+		sock := filepath.Join(dir, "d.sock")
+		ln, err := net.Listen("unix", sock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := render(t, sock, `{}`); !errors.Is(err, xdg.ErrNotPrivate) {
+			t.Errorf("Call in %s = %v, want ErrNotPrivate", dir, err)
+		}
+		if err := Supervise(sock, time.Minute, os.Args[0], nil); !errors.Is(err, xdg.ErrNotPrivate) {
+			t.Errorf("Supervise in %s = %v, want ErrNotPrivate", dir, err)
+		}
+		_ = ln.Close()
+	}
+}
@@ -0,0 +1,239 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hergert/ccsl/internal/xdg"
+)
+
+const (
+	// hungAfter is how long the plugin gets to answer before it is killed
+	// and restarted. Callers give up much sooner; this catches plugins that
+	// stopped answering altogether.
+	hungAfter = 5 * time.Second
+
+	// A plugin that keeps crashing is restarted after a growing pause, up
+	// to maxBackoff; one that ran for stableAfter starts over at minBackoff.
+	minBackoff  = 100 * time.Millisecond
+	maxBackoff  = 30 * time.Second
+	stableAfter = time.Minute
+
+	// stopGrace is how long the plugin has to exit after "shutdown".
+	stopGrace = time.Second
+)
+
+var errBackoff = errors.New("plugin crashed; waiting to restart")
+
+// Supervise serves sock until no request has come in for idle, running
+// command as the plugin. It returns at once when another supervisor
+// already serves sock.
+func Supervise(sock string, idle time.Duration, command string, args []string) error {
+	if idle <= 0 {
+		idle = DefaultIdle
+	}
+	if err := xdg.PrivateDir(filepath.Dir(sock)); err != nil {
+		return err
+	}
+	if conn, err := net.Dial("unix", sock); err == nil {
+		_ = conn.Close()
+		return nil
+	}
+	_ = os.Remove(sock) // left by a supervisor that died
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		return err // most likely another supervisor won the race
+	}
+
+	s := &supervisor{command: command, args: args}
+	defer s.stop()
+	idleTimer := time.AfterFunc(idle, func() { _ = ln.Close() })
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return nil // closed when idle
+		}
+		idleTimer.Reset(idle)
+		go s.serve(conn)
+	}
+}
+
+// supervisor owns one plugin process; calls to it are serialized.
+type supervisor struct {
+	command string
+	args    []string
+
+	mu       sync.Mutex
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	lines    chan []byte   // the plugin's stdout, closed at EOF
+	exited   chan struct{} // closed once the plugin has been reaped
+	started  time.Time
+	seq      int64
+	failures int
+	retryAt  time.Time
+}
+
+func (s *supervisor) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(hungAfter + time.Second))
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return
+	}
+	var req request
+	if err := json.Unmarshal(line, &req); err != nil {
+		return
+	}
+	data, _ := json.Marshal(s.call(req))
+	_, _ = conn.Write(append(data, '\n'))
+}
+
+// call forwards req under an id of the supervisor's own, so a late answer
+// to a call that already gave up is never taken for this one's.
+func (s *supervisor) call(req request) response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fail := func(err error) response {
+		return response{JSONRPC: "2.0", ID: req.ID, Error: &rpcError{Code: -32603, Message: err.Error()}}
+	}
+	if err := s.ensure(); err != nil {
+		return fail(err)
+	}
+
+	s.seq++
+	fwd := req
+	fwd.ID = s.seq
+	data, _ := json.Marshal(fwd)
+	if _, err := s.stdin.Write(append(data, '\n')); err != nil {
+		s.crashed()
+		return fail(err)
+	}
+
+	timeout := time.NewTimer(hungAfter)
+	defer timeout.Stop()
+	for {
+		select {
+		case line, ok := <-s.lines:
+			if !ok {
+				s.crashed()
+				return fail(errors.New("plugin exited"))
+			}
+			var resp response
+			if json.Unmarshal(line, &resp) != nil || resp.ID != fwd.ID {
+				continue
+			}
+			resp.JSONRPC, resp.ID = "2.0", req.ID
+			return resp
+		case <-timeout.C:
+			s.kill()
+			s.crashed()
+			return fail(errors.New("plugin did not answer"))
+		}
+	}
+}
+
+// ensure has the plugin running, restarting it if it exited since the last
+// call, unless it is crashing too often to try again yet.
+func (s *supervisor) ensure() error {
+	if s.cmd != nil {
+		select {
+		case <-s.exited:
+			s.crashed()
+		default:
+			return nil
+		}
+	}
+	if time.Now().Before(s.retryAt) {
+		return errBackoff
+	}
+
+	cmd := exec.Command(s.command, s.args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		s.failures++
+		s.retryAt = time.Now().Add(backoff(s.failures))
+		return err
+	}
+
+	lines := make(chan []byte, 16)
+	exited := make(chan struct{})
+	go func() {
+		sc := bufio.NewScanner(stdout)
+		sc.Buffer(make([]byte, 64*1024), 1<<20)
+		for sc.Scan() {
+			select {
+			case lines <- append([]byte(nil), sc.Bytes()...):
+			default: // nobody asked; don't let chatter block the plugin
+			}
+		}
+		close(lines)
+		_ = cmd.Wait()
+		close(exited)
+	}()
+	s.cmd, s.stdin, s.lines, s.exited, s.started = cmd, stdin, lines, exited, time.Now()
+	return nil
+}
+
+// crashed forgets the plugin and schedules its restart.
+func (s *supervisor) crashed() {
+	if s.cmd == nil {
+		return
+	}
+	s.kill()
+	if time.Since(s.started) >= stableAfter {
+		s.failures = 0
+	}
+	s.failures++
+	s.retryAt = time.Now().Add(backoff(s.failures))
+	s.cmd = nil
+}
+
+func (s *supervisor) kill() {
+	if s.cmd != nil && s.cmd.Process != nil {
+		_ = s.cmd.Process.Kill()
+	}
+}
+
+// stop asks the plugin to shut down and closes its stdin, killing it if it
+// is still around after stopGrace.
+func (s *supervisor) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cmd == nil {
+		return
+	}
+	data, _ := json.Marshal(request{JSONRPC: "2.0", Method: "shutdown"})
+	_, _ = s.stdin.Write(append(data, '\n'))
+	_ = s.stdin.Close()
+	select {
+	case <-s.exited:
+	case <-time.After(stopGrace):
+		s.kill()
+	}
+	s.cmd = nil
+}
+
+func backoff(failures int) time.Duration {
+	d := minBackoff
+	for i := 1; i < failures && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
@@ -0,0 +1,16 @@
+// Package detach starts helper processes that outlive the short-lived ccsl
+// invocation that spawned them.
+package detach
+
+import "os/exec"
+
+// Start runs cmd in its own session with no stdio attached and releases it,
+// so the caller can exit without waiting for (or killing) the child.
+func Start(cmd *exec.Cmd) error {
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = nil, nil, nil
+	setSession(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
@@ -0,0 +1,7 @@
+//go:build !unix
+
+package detach
+
+import "os/exec"
+
+func setSession(cmd *exec.Cmd) {}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package detach
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// A new session keeps the child alive when the terminal's process group
+// is signalled after ccsl exits.
+func setSession(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
@@ -0,0 +1,125 @@
+// Package forge turns a repository's remote into web URLs on its forge
+// (GitHub, GitLab, Bitbucket, or anything laid out like GitHub), for the
+// hyperlinks on the git and pr segments.
+package forge
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/hergert/ccsl/internal/gitrepo"
+)
+
+// Repo is a repository's home page, e.g. https://github.com/hergert/ccsl.
+type Repo struct {
+	Host   string
+	Path   string // owner/name, without ".git"
+	Branch string // the remote branch HEAD's branch merges from, when configured
+}
+
+// Lookup finds the web home of the repository containing dir from the
+// branch's remote, or origin, along with the branch's name there (from
+// branch.<name>.merge). Layouts gitrepo can't read have no links.
+func Lookup(dir string) (Repo, bool) {
+	r, err := gitrepo.Open(dir)
+	if err != nil {
+		return Repo{}, false
+	}
+	defer func() { _ = r.Close() }()
+
+	remote, upstream := "origin", ""
+	if branch, _, err := r.Head(); err == nil && branch != "" {
+		if name, ok := r.Config("branch." + branch + ".remote"); ok && name != "." {
+			remote = name
+			if merge, ok := r.Config("branch." + branch + ".merge"); ok {
+				upstream, _ = strings.CutPrefix(merge, "refs/heads/")
+			}
+		}
+	}
+	u, ok := r.Config("remote." + remote + ".url")
+	if !ok {
+		return Repo{}, false
+	}
+	repo, ok := Parse(u)
+	if !ok {
+		return Repo{}, false
+	}
+	repo.Branch = upstream
+	return repo, true
+}
+
+// Parse reads a remote URL in any of git's forms: https://host/o/r.git,
+// ssh://git@host:22/o/r.git or scp-like git@host:o/r.git.
+func Parse(remote string) (Repo, bool) {
+	var host, path string
+	if strings.Contains(remote, "://") {
+		u, err := url.Parse(remote)
+		if err != nil {
+			return Repo{}, false
+		}
+		switch u.Scheme {
+		case "http", "https", "ssh", "git", "git+ssh", "ssh+git":
+		default:
+			return Repo{}, false
+		}
+		host, path = u.Hostname(), u.Path
+	} else if at, rest, ok := strings.Cut(remote, ":"); ok && !strings.Contains(at, "/") {
+		host, path = at[strings.LastIndexByte(at, '@')+1:], rest
+	} else {
+		return Repo{}, false
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if host == "" || !strings.Contains(path, "/") {
+		return Repo{}, false
+	}
+	return Repo{Host: host, Path: path}, true
+}
+
+func (r Repo) URL() string {
+	return "https://" + r.Host + "/" + r.Path
+}
+
+func (r Repo) kind() string {
+	switch {
+	case strings.Contains(r.Host, "gitlab"):
+		return "gitlab"
+	case r.Host == "bitbucket.org":
+		return "bitbucket"
+	}
+	return "github"
+}
+
+// BranchURL is the page for branch (or a commit, detached).
+func (r Repo) BranchURL(branch string) string {
+	ref := escapeRef(branch)
+	switch r.kind() {
+	case "gitlab":
+		return r.URL() + "/-/tree/" + ref
+	case "bitbucket":
+		return r.URL() + "/src/" + ref
+	}
+	return r.URL() + "/tree/" + ref
+}
+
+// PullURL is the page for pull (or merge) request number n.
+func (r Repo) PullURL(n int) string {
+	num := strconv.Itoa(n)
+	switch r.kind() {
+	case "gitlab":
+		return r.URL() + "/-/merge_requests/" + num
+	case "bitbucket":
+		return r.URL() + "/pull-requests/" + num
+	}
+	return r.URL() + "/pull/" + num
+}
+
+// escapeRef escapes each element of a ref, keeping its slashes.
+func escapeRef(ref string) string {
+	parts := strings.Split(ref, "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,174 @@
+package gitrepo
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	flagAssumeValid  = 0x8000
+	flagExtended     = 0x4000
+	flagStageMask    = 0x3000
+	flagSkipWorktree = 0x4000 // extended flags
+	flagIntentToAdd  = 0x2000 // extended flags
+)
+
+type indexEntry struct {
+	mtimeSec  uint32
+	mtimeNsec uint32
+	mode      uint32
+	size      uint32
+	oid       Hash
+	flags     uint16
+	extFlags  uint16
+	path      string
+}
+
+func (e indexEntry) stage() int { return int(e.flags&flagStageMask) >> 12 }
+
+type index struct {
+	entries []indexEntry
+	mtime   time.Time // of the index file, for racy-git detection
+	// Root of the cache-tree extension, when it is valid.
+	tree    Hash
+	hasTree bool
+}
+
+var errBadIndex = errors.New("gitrepo: corrupt index")
+
+// readIndex parses .git/index (versions 2-4). Split indexes and sparse
+// directory entries are reported as ErrUnsupported.
+func (r *Repo) readIndex() (*index, error) {
+	path := filepath.Join(r.GitDir, "index")
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &index{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 12+20 || string(data[:4]) != "DIRC" {
+		return nil, errBadIndex
+	}
+	// index.skipHash writes a zero trailer instead of a checksum.
+	if trailer := data[len(data)-20:]; !bytes.Equal(trailer, make([]byte, 20)) {
+		if sum := sha1.Sum(data[:len(data)-20]); !bytes.Equal(sum[:], trailer) {
+			return nil, errBadIndex
+		}
+	}
+	version := binary.BigEndian.Uint32(data[4:8])
+	if version < 2 || version > 4 {
+		return nil, ErrUnsupported
+	}
+	count := int(binary.BigEndian.Uint32(data[8:12]))
+	body := data[:len(data)-20]
+
+	idx := &index{mtime: fi.ModTime(), entries: make([]indexEntry, 0, count)}
+	pos := 12
+	prev := ""
+	for i := 0; i < count; i++ {
+		start := pos
+		if pos+62 > len(body) {
+			return nil, errBadIndex
+		}
+		var e indexEntry
+		e.mtimeSec = binary.BigEndian.Uint32(body[pos+8:])
+		e.mtimeNsec = binary.BigEndian.Uint32(body[pos+12:])
+		e.mode = binary.BigEndian.Uint32(body[pos+24:])
+		e.size = binary.BigEndian.Uint32(body[pos+36:])
+		copy(e.oid[:], body[pos+40:pos+60])
+		e.flags = binary.BigEndian.Uint16(body[pos+60:])
+		pos += 62
+		if e.flags&flagExtended != 0 {
+			if version < 3 || pos+2 > len(body) {
+				return nil, errBadIndex
+			}
+			e.extFlags = binary.BigEndian.Uint16(body[pos:])
+			pos += 2
+		}
+
+		if version == 4 {
+			br := bytes.NewReader(body[pos:])
+			strip, err := readOffsetVarint(br)
+			if err != nil || int(strip) > len(prev) {
+				return nil, errBadIndex
+			}
+			pos = len(body) - br.Len()
+			nul := bytes.IndexByte(body[pos:], 0)
+			if nul < 0 {
+				return nil, errBadIndex
+			}
+			e.path = prev[:len(prev)-int(strip)] + string(body[pos:pos+nul])
+			pos += nul + 1
+		} else {
+			nul := bytes.IndexByte(body[pos:], 0)
+			if nul < 0 {
+				return nil, errBadIndex
+			}
+			e.path = string(body[pos : pos+nul])
+			// Entries are NUL-padded to a multiple of eight bytes.
+			pos = start + ((pos+nul-start)/8+1)*8
+		}
+		prev = e.path
+
+		if e.extFlags&flagSkipWorktree != 0 {
+			return nil, ErrUnsupported
+		}
+		idx.entries = append(idx.entries, e)
+	}
+
+	for pos+8 <= len(body) {
+		sig := string(body[pos : pos+4])
+		size := int(binary.BigEndian.Uint32(body[pos+4:]))
+		pos += 8
+		if pos+size > len(body) {
+			return nil, errBadIndex
+		}
+		ext := body[pos : pos+size]
+		pos += size
+
+		switch sig {
+		case "link", "sdir":
+			return nil, ErrUnsupported
+		case "TREE":
+			idx.tree, idx.hasTree = cacheTreeRoot(ext, len(idx.entries))
+		}
+	}
+	return idx, nil
+}
+
+// cacheTreeRoot returns the root tree recorded by the TREE extension if it
+// is still valid for the whole index.
+func cacheTreeRoot(ext []byte, entries int) (Hash, bool) {
+	br := bufio.NewReader(bytes.NewReader(ext))
+	path, err := br.ReadString(0)
+	if err != nil || path != "\x00" {
+		return Hash{}, false
+	}
+	counts, err := br.ReadString('\n')
+	if err != nil {
+		return Hash{}, false
+	}
+	n, _, _ := bytes.Cut([]byte(counts), []byte{' '})
+	count, err := strconv.Atoi(string(n))
+	if err != nil || count < 0 || count != entries {
+		return Hash{}, false
+	}
+	var h Hash
+	if _, err := io.ReadFull(br, h[:]); err != nil {
+		return Hash{}, false
+	}
+	return h, true
+}
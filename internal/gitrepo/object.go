@@ -0,0 +1,438 @@
+package gitrepo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type objectType int
+
+const (
+	objCommit   objectType = 1
+	objTree     objectType = 2
+	objBlob     objectType = 3
+	objTag      objectType = 4
+	objOfsDelta objectType = 6
+	objRefDelta objectType = 7
+)
+
+var typeNames = map[string]objectType{
+	"commit": objCommit,
+	"tree":   objTree,
+	"blob":   objBlob,
+	"tag":    objTag,
+}
+
+// Objects larger than this are never inflated; the reader only needs
+// commits and trees, which stay far below it.
+const maxObjectSize = 64 << 20
+
+type objectStore struct {
+	dirs   []string // objects dir followed by alternates
+	packs  []*pack
+	loaded bool
+}
+
+func newObjectStore(dir string) *objectStore {
+	s := &objectStore{dirs: []string{dir}}
+	if data, err := os.ReadFile(filepath.Join(dir, "info", "alternates")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || line[0] == '#' {
+				continue
+			}
+			if !filepath.IsAbs(line) {
+				line = filepath.Join(dir, line)
+			}
+			s.dirs = append(s.dirs, filepath.Clean(line))
+		}
+	}
+	return s
+}
+
+func (s *objectStore) close() error {
+	for _, p := range s.packs {
+		_ = p.f.Close()
+	}
+	s.packs = nil
+	s.loaded = false
+	return nil
+}
+
+func (s *objectStore) loadPacks() error {
+	if s.loaded {
+		return nil
+	}
+	s.loaded = true
+	for _, dir := range s.dirs {
+		idxs, _ := filepath.Glob(filepath.Join(dir, "pack", "pack-*.idx"))
+		for _, idx := range idxs {
+			p, err := openPack(strings.TrimSuffix(idx, ".idx"))
+			if err != nil {
+				return err
+			}
+			s.packs = append(s.packs, p)
+		}
+	}
+	return nil
+}
+
+// count approximates the number of objects, as git does for abbreviations.
+func (s *objectStore) count() int {
+	_ = s.loadPacks()
+	n := 0
+	for _, p := range s.packs {
+		n += p.n
+	}
+	// Sample one fan-out directory and scale, like git's estimate.
+	if entries, err := os.ReadDir(filepath.Join(s.dirs[0], "17")); err == nil {
+		n += len(entries) * 256
+	}
+	return n
+}
+
+func (s *objectStore) read(h Hash) (objectType, []byte, error) {
+	for _, dir := range s.dirs {
+		hex := h.String()
+		f, err := os.Open(filepath.Join(dir, hex[:2], hex[2:]))
+		if err != nil {
+			continue
+		}
+		t, data, err := readLoose(f)
+		_ = f.Close()
+		return t, data, err
+	}
+
+	if err := s.loadPacks(); err != nil {
+		return 0, nil, err
+	}
+	for _, p := range s.packs {
+		if off, ok := p.find(h); ok {
+			return p.readAt(s, off, 0)
+		}
+	}
+	return 0, nil, fmt.Errorf("%w: object %s", ErrNotFound, h)
+}
+
+func readLoose(r io.Reader) (objectType, []byte, error) {
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer func() { _ = zr.Close() }()
+
+	br := bufio.NewReader(zr)
+	header, err := br.ReadString(0)
+	if err != nil {
+		return 0, nil, err
+	}
+	typ, size, ok := strings.Cut(strings.TrimSuffix(header, "\x00"), " ")
+	t, known := typeNames[typ]
+	if !ok || !known {
+		return 0, nil, fmt.Errorf("gitrepo: bad loose object header %q", header)
+	}
+	n, err := strconv.Atoi(size)
+	if err != nil || n > maxObjectSize {
+		return 0, nil, ErrUnsupported
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(br, data); err != nil {
+		return 0, nil, err
+	}
+	return t, data, nil
+}
+
+type pack struct {
+	f       *os.File
+	n       int
+	fanout  [256]uint32
+	hashes  []byte // n * 20
+	offsets []byte // n * 4
+	large   []byte // 8-byte offsets for packs over 2 GiB
+}
+
+func openPack(base string) (*pack, error) {
+	idx, err := os.ReadFile(base + ".idx")
+	if err != nil {
+		return nil, err
+	}
+	// Only version 2 indexes (git >= 1.5.2); v1 has no magic header.
+	if len(idx) < 8+256*4 || !bytes.Equal(idx[:4], []byte{0xff, 't', 'O', 'c'}) ||
+		binary.BigEndian.Uint32(idx[4:8]) != 2 {
+		return nil, ErrUnsupported
+	}
+	p := &pack{}
+	for i := range p.fanout {
+		p.fanout[i] = binary.BigEndian.Uint32(idx[8+i*4:])
+	}
+	p.n = int(p.fanout[255])
+	pos := 8 + 256*4
+	if len(idx) < pos+p.n*(20+4+4) {
+		return nil, errors.New("gitrepo: truncated pack index")
+	}
+	p.hashes = idx[pos : pos+p.n*20]
+	pos += p.n * 20
+	pos += p.n * 4 // crc32 table
+	p.offsets = idx[pos : pos+p.n*4]
+	p.large = idx[pos+p.n*4:]
+
+	if p.f, err = os.Open(base + ".pack"); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *pack) find(h Hash) (int64, bool) {
+	lo := 0
+	if h[0] > 0 {
+		lo = int(p.fanout[h[0]-1])
+	}
+	hi := int(p.fanout[h[0]])
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.hashes[(lo+i)*20:(lo+i+1)*20], h[:]) >= 0
+	})
+	if i >= hi || !bytes.Equal(p.hashes[i*20:(i+1)*20], h[:]) {
+		return 0, false
+	}
+	off := binary.BigEndian.Uint32(p.offsets[i*4:])
+	if off&0x80000000 == 0 {
+		return int64(off), true
+	}
+	j := int(off&0x7fffffff) * 8
+	if j+8 > len(p.large) {
+		return 0, false
+	}
+	return int64(binary.BigEndian.Uint64(p.large[j:])), true
+}
+
+// readAt inflates the entry at off, resolving delta chains.
+func (p *pack) readAt(s *objectStore, off int64, depth int) (objectType, []byte, error) {
+	if depth > 64 {
+		return 0, nil, ErrUnsupported
+	}
+	br := bufio.NewReader(io.NewSectionReader(p.f, off, 1<<62))
+	c, err := br.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	t := objectType((c >> 4) & 7)
+	size := int64(c & 0x0f)
+	for shift := 4; c&0x80 != 0; shift += 7 {
+		if c, err = br.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+		size |= int64(c&0x7f) << shift
+	}
+	if size > maxObjectSize {
+		return 0, nil, ErrUnsupported
+	}
+
+	var baseType objectType
+	var base []byte
+	switch t {
+	case objCommit, objTree, objBlob, objTag:
+	case objOfsDelta:
+		rel, err := readOffsetVarint(br)
+		if err != nil {
+			return 0, nil, err
+		}
+		if baseType, base, err = p.readAt(s, off-rel, depth+1); err != nil {
+			return 0, nil, err
+		}
+	case objRefDelta:
+		var h Hash
+		if _, err := io.ReadFull(br, h[:]); err != nil {
+			return 0, nil, err
+		}
+		if baseType, base, err = s.read(h); err != nil {
+			return 0, nil, err
+		}
+	default:
+		return 0, nil, fmt.Errorf("gitrepo: bad pack entry type %d", t)
+	}
+
+	zr, err := zlib.NewReader(br)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer func() { _ = zr.Close() }()
+	data := make([]byte, size)
+	if _, err := io.ReadFull(zr, data); err != nil {
+		return 0, nil, err
+	}
+
+	if base == nil {
+		return t, data, nil
+	}
+	out, err := applyDelta(base, data)
+	return baseType, out, err
+}
+
+// readOffsetVarint decodes git's big-endian "offset encoding", used for
+// OFS_DELTA bases and index v4 path prefixes.
+func readOffsetVarint(br io.ByteReader) (int64, error) {
+	c, err := br.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	v := int64(c & 0x7f)
+	for c&0x80 != 0 {
+		if c, err = br.ReadByte(); err != nil {
+			return 0, err
+		}
+		v = ((v + 1) << 7) | int64(c&0x7f)
+	}
+	return v, nil
+}
+
+func applyDelta(base, delta []byte) ([]byte, error) {
+	errBad := errors.New("gitrepo: corrupt delta")
+	pos := 0
+	readSize := func() (int, bool) {
+		v, shift := 0, 0
+		for pos < len(delta) {
+			c := delta[pos]
+			pos++
+			v |= int(c&0x7f) << shift
+			shift += 7
+			if c&0x80 == 0 {
+				return v, true
+			}
+		}
+		return 0, false
+	}
+
+	srcSize, ok1 := readSize()
+	dstSize, ok2 := readSize()
+	if !ok1 || !ok2 || srcSize != len(base) || dstSize > maxObjectSize {
+		return nil, errBad
+	}
+	out := make([]byte, 0, dstSize)
+	for pos < len(delta) {
+		cmd := delta[pos]
+		pos++
+		switch {
+		case cmd&0x80 != 0:
+			var off, n int
+			for i := 0; i < 4; i++ {
+				if cmd&(1<<i) != 0 {
+					if pos >= len(delta) {
+						return nil, errBad
+					}
+					off |= int(delta[pos]) << (8 * i)
+					pos++
+				}
+			}
+			for i := 0; i < 3; i++ {
+				if cmd&(0x10<<i) != 0 {
+					if pos >= len(delta) {
+						return nil, errBad
+					}
+					n |= int(delta[pos]) << (8 * i)
+					pos++
+				}
+			}
+			if n == 0 {
+				n = 0x10000
+			}
+			if off+n > len(base) {
+				return nil, errBad
+			}
+			out = append(out, base[off:off+n]...)
+		case cmd != 0:
+			if pos+int(cmd) > len(delta) {
+				return nil, errBad
+			}
+			out = append(out, delta[pos:pos+int(cmd)]...)
+			pos += int(cmd)
+		default:
+			return nil, errBad
+		}
+	}
+	if len(out) != dstSize {
+		return nil, errBad
+	}
+	return out, nil
+}
+
+type commit struct {
+	tree    Hash
+	parents []Hash
+	time    int64 // committer timestamp
+}
+
+func (r *Repo) readCommit(h Hash) (commit, error) {
+	t, data, err := r.objects.read(h)
+	if err != nil {
+		return commit{}, err
+	}
+	if t != objCommit {
+		return commit{}, fmt.Errorf("gitrepo: %s is not a commit", h)
+	}
+	var c commit
+	for len(data) > 0 {
+		line, rest, _ := bytes.Cut(data, []byte{'\n'})
+		data = rest
+		if len(line) == 0 {
+			break // end of headers
+		}
+		key, value, _ := bytes.Cut(line, []byte{' '})
+		switch string(key) {
+		case "tree":
+			c.tree, _ = parseHash(string(value))
+		case "parent":
+			if p, ok := parseHash(string(value)); ok {
+				c.parents = append(c.parents, p)
+			}
+		case "committer":
+			fields := bytes.Fields(value)
+			if len(fields) >= 2 {
+				c.time, _ = strconv.ParseInt(string(fields[len(fields)-2]), 10, 64)
+			}
+		}
+	}
+	return c, nil
+}
+
+type treeEntry struct {
+	mode uint32
+	name string
+	oid  Hash
+}
+
+func (r *Repo) readTree(h Hash) ([]treeEntry, error) {
+	t, data, err := r.objects.read(h)
+	if err != nil {
+		return nil, err
+	}
+	if t != objTree {
+		return nil, fmt.Errorf("gitrepo: %s is not a tree", h)
+	}
+	var entries []treeEntry
+	for len(data) > 0 {
+		sp := bytes.IndexByte(data, ' ')
+		nul := bytes.IndexByte(data, 0)
+		if sp < 0 || nul < sp || nul+21 > len(data) {
+			return nil, errors.New("gitrepo: corrupt tree")
+		}
+		mode, err := strconv.ParseUint(string(data[:sp]), 8, 32)
+		if err != nil {
+			return nil, err
+		}
+		e := treeEntry{mode: uint32(mode), name: string(data[sp+1 : nul])}
+		copy(e.oid[:], data[nul+1:nul+21])
+		entries = append(entries, e)
+		data = data[nul+21:]
+	}
+	return entries, nil
+}
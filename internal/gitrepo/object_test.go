@@ -0,0 +1,240 @@
+package gitrepo
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestApplyDelta(t *testing.T) {
+	base := []byte("hello world")
+	cases := []struct {
+		name  string
+		delta []byte
+		want  string
+		bad   bool
+	}{
+		// copy 6 bytes from offset 0, then insert "there"
+		{"copy and insert", []byte{11, 11, 0x90, 6, 5, 't', 'h', 'e', 'r', 'e'}, "hello there", false},
+		// copy 5 bytes from offset 6
+		{"copy with offset", []byte{11, 5, 0x91, 6, 5}, "world", false},
+		{"empty result", []byte{11, 0}, "", false},
+		{"wrong base size", []byte{10, 11, 0x90, 11}, "", true},
+		{"truncated sizes", []byte{11, 0x80}, "", true},
+		{"no sizes", nil, "", true},
+		{"truncated copy offset", []byte{11, 5, 0x91}, "", true},
+		{"truncated copy size", []byte{11, 5, 0x91, 6}, "", true},
+		{"copy past base", []byte{11, 6, 0x91, 6, 6}, "", true},
+		{"truncated insert", []byte{11, 5, 5, 'a', 'b'}, "", true},
+		{"reserved opcode", []byte{11, 1, 0}, "", true},
+		{"short result", []byte{11, 12, 0x90, 11}, "", true},
+		{"long result", []byte{11, 5, 0x90, 11}, "", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := applyDelta(base, tc.delta)
+			if tc.bad {
+				if err == nil {
+					t.Errorf("applyDelta = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil || string(got) != tc.want {
+				t.Errorf("applyDelta = %q, %v; want %q", got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestReadOffsetVarint(t *testing.T) {
+	cases := []struct {
+		in   []byte
+		want int64
+	}{
+		{[]byte{0x00}, 0},
+		{[]byte{0x7f}, 127},
+		{[]byte{0x80, 0x00}, 128},
+		{[]byte{0x81, 0x00}, 256},
+		{[]byte{0xff, 0x7f}, 16511},
+		{[]byte{0x80, 0x80, 0x00}, 16512},
+	}
+	for _, tc := range cases {
+		got, err := readOffsetVarint(bytes.NewReader(tc.in))
+		if err != nil || got != tc.want {
+			t.Errorf("readOffsetVarint(%x) = %d, %v; want %d", tc.in, got, err, tc.want)
+		}
+		if enc := encodeOffset(tc.want); !bytes.Equal(enc, tc.in) {
+			t.Errorf("encodeOffset(%d) = %x, want %x", tc.want, enc, tc.in)
+		}
+	}
+	if _, err := readOffsetVarint(bytes.NewReader([]byte{0x80})); err == nil {
+		t.Error("truncated offset decoded")
+	}
+}
+
+// encodeOffset is git's OFS_DELTA offset encoding, the inverse of
+// readOffsetVarint.
+func encodeOffset(v int64) []byte {
+	out := []byte{byte(v & 0x7f)}
+	for v >>= 7; v != 0; v >>= 7 {
+		v--
+		out = append([]byte{0x80 | byte(v&0x7f)}, out...)
+	}
+	return out
+}
+
+func hashOf(first byte, rest byte) Hash {
+	var h Hash
+	h[0], h[19] = first, rest
+	return h
+}
+
+// writeIndex writes a version 2 pack index for objects at offsets,
+// spilling those past 2 GiB into the large offset table as git does.
+func writeIndex(t *testing.T, base string, offsets map[Hash]uint64) {
+	t.Helper()
+	hashes := make([]Hash, 0, len(offsets))
+	for h := range offsets {
+		hashes = append(hashes, h)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return bytes.Compare(hashes[i][:], hashes[j][:]) < 0 })
+
+	var buf bytes.Buffer
+	buf.Write([]byte{0xff, 't', 'O', 'c', 0, 0, 0, 2})
+	var fanout [256]uint32
+	for _, h := range hashes {
+		for i := int(h[0]); i < 256; i++ {
+			fanout[i]++
+		}
+	}
+	_ = binary.Write(&buf, binary.BigEndian, fanout)
+	for _, h := range hashes {
+		buf.Write(h[:])
+	}
+	buf.Write(make([]byte, 4*len(hashes))) // crc32
+	var large []uint64
+	for _, h := range hashes {
+		off := offsets[h]
+		if off >= 1<<31 {
+			_ = binary.Write(&buf, binary.BigEndian, uint32(0x80000000|len(large)))
+			large = append(large, off)
+			continue
+		}
+		_ = binary.Write(&buf, binary.BigEndian, uint32(off))
+	}
+	_ = binary.Write(&buf, binary.BigEndian, large)
+	if err := os.WriteFile(base+".idx", buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPackFind(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "pack-test")
+	offsets := map[Hash]uint64{
+		hashOf(0x00, 1): 12,
+		hashOf(0xab, 1): 40,
+		hashOf(0xab, 2): 5 << 30,
+		hashOf(0xff, 9): 1<<31 - 1,
+	}
+	writeIndex(t, base, offsets)
+	if err := os.WriteFile(base+".pack", nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := openPack(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.f.Close()
+
+	if p.n != len(offsets) {
+		t.Errorf("n = %d, want %d", p.n, len(offsets))
+	}
+	for h, want := range offsets {
+		if got, ok := p.find(h); !ok || uint64(got) != want {
+			t.Errorf("find(%s) = %d, %v; want %d", h, got, ok, want)
+		}
+	}
+	for _, h := range []Hash{hashOf(0xab, 3), hashOf(0x01, 1), hashOf(0xff, 0)} {
+		if off, ok := p.find(h); ok {
+			t.Errorf("find(%s) = %d for a missing object", h, off)
+		}
+	}
+
+	// A large offset pointing past the table is corrupt, not a crash.
+	p.large = p.large[:4]
+	if _, ok := p.find(hashOf(0xab, 2)); ok {
+		t.Error("find read past the large offset table")
+	}
+}
+
+func TestOpenPackRejects(t *testing.T) {
+	dir := t.TempDir()
+	v1 := filepath.Join(dir, "pack-v1")
+	if err := os.WriteFile(v1+".idx", make([]byte, 256*4+20), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openPack(v1); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("v1 index: %v, want ErrUnsupported", err)
+	}
+
+	short := filepath.Join(dir, "pack-short")
+	writeIndex(t, short, map[Hash]uint64{hashOf(1, 1): 12, hashOf(2, 2): 40})
+	idx, _ := os.ReadFile(short + ".idx")
+	if err := os.WriteFile(short+".idx", idx[:len(idx)-10], 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openPack(short); err == nil || errors.Is(err, ErrUnsupported) {
+		t.Errorf("truncated index: %v, want a corruption error", err)
+	}
+}
+
+// TestPackReadDelta reads an object stored as an OFS_DELTA against a blob
+// earlier in the same pack.
+func TestPackReadDelta(t *testing.T) {
+	entry := func(typ objectType, size int, extra, data []byte) []byte {
+		hdr := []byte{byte(typ)<<4 | byte(size&0x0f)}
+		for size >>= 4; size != 0; size >>= 7 {
+			hdr[len(hdr)-1] |= 0x80
+			hdr = append(hdr, byte(size&0x7f))
+		}
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		_, _ = zw.Write(data)
+		_ = zw.Close()
+		return append(append(hdr, extra...), z.Bytes()...)
+	}
+
+	blob := bytes.Repeat([]byte("0123456789"), 3)
+	delta := []byte{30, 12, 0x90, 4, 8, 'a', 'b', 'c', 'd', 'e', 'f', 'g', 'h'}
+	pack := []byte("PACK\x00\x00\x00\x02\x00\x00\x00\x02")
+	blobAt := len(pack)
+	pack = append(pack, entry(objBlob, len(blob), nil, blob)...)
+	deltaAt := len(pack)
+	pack = append(pack, entry(objOfsDelta, len(delta), encodeOffset(int64(deltaAt-blobAt)), delta)...)
+
+	dir := t.TempDir()
+	base := filepath.Join(dir, "pack", "pack-test")
+	if err := os.MkdirAll(filepath.Dir(base), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(base+".pack", pack, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	blobID, deltaID := hashOf(0x10, 1), hashOf(0x20, 2)
+	writeIndex(t, base, map[Hash]uint64{blobID: uint64(blobAt), deltaID: uint64(deltaAt)})
+
+	s := newObjectStore(dir)
+	defer s.close()
+	typ, data, err := s.read(deltaID)
+	if err != nil || typ != objBlob || string(data) != "0123abcdefgh" {
+		t.Errorf("read = %d, %q, %v; want blob %q", typ, data, err, "0123abcdefgh")
+	}
+	if _, _, err := s.read(hashOf(0x30, 3)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("read of a missing object = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,380 @@
+// Package gitrepo reads just enough of a git repository's on-disk format
+// (refs, config, objects, index) to answer the questions the status line
+// asks, without spawning the git binary. Anything it does not understand
+// surfaces as ErrUnsupported so callers can fall back to git itself.
+package gitrepo
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrUnsupported means the repository uses a feature this reader does not
+	// implement (split index, sparse checkout, reftable, SHA-256, ...).
+	ErrUnsupported = errors.New("gitrepo: unsupported repository layout")
+	ErrNotRepo     = errors.New("gitrepo: not a git repository")
+	ErrNotFound    = errors.New("gitrepo: not found")
+)
+
+// Hash is a SHA-1 object id.
+type Hash [20]byte
+
+func (h Hash) String() string { return hex.EncodeToString(h[:]) }
+
+func (h Hash) IsZero() bool { return h == Hash{} }
+
+func parseHash(s string) (Hash, bool) {
+	var h Hash
+	if len(s) != 40 {
+		return h, false
+	}
+	if _, err := hex.Decode(h[:], []byte(s)); err != nil {
+		return h, false
+	}
+	return h, true
+}
+
+type Repo struct {
+	WorkTree  string
+	GitDir    string // per-worktree dir: HEAD, index
+	CommonDir string // shared dir: objects, refs, config
+
+	config  map[string]string
+	objects *objectStore
+}
+
+// Extensions that do not change how refs, objects or the index are read.
+var harmlessExtensions = map[string]bool{
+	"noop":            true,
+	"preciousobjects": true,
+	"worktreeconfig":  true,
+}
+
+// Open finds the repository containing dir by walking up to the nearest .git
+// entry, the way git's discovery does for the common cases.
+func Open(dir string) (*Repo, error) {
+	if os.Getenv("GIT_DIR") != "" || os.Getenv("GIT_WORK_TREE") != "" ||
+		os.Getenv("GIT_CEILING_DIRECTORIES") != "" || os.Getenv("GIT_INDEX_FILE") != "" ||
+		os.Getenv("GIT_OBJECT_DIRECTORY") != "" || os.Getenv("GIT_COMMON_DIR") != "" {
+		return nil, ErrUnsupported
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for d := abs; ; {
+		if gitDir, ok := gitDirAt(d); ok {
+			return openAt(d, gitDir)
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return nil, ErrNotRepo
+		}
+		d = parent
+	}
+}
+
+// gitDirAt resolves dir/.git, following the "gitdir: <path>" file used by
+// linked worktrees and submodules.
+func gitDirAt(dir string) (string, bool) {
+	dotGit := filepath.Join(dir, ".git")
+	fi, err := os.Stat(dotGit)
+	if err != nil {
+		return "", false
+	}
+	if fi.IsDir() {
+		return dotGit, true
+	}
+	data, err := os.ReadFile(dotGit)
+	if err != nil {
+		return "", false
+	}
+	line := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(line, "gitdir:") {
+		return "", false
+	}
+	target := strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(dir, target)
+	}
+	return filepath.Clean(target), true
+}
+
+func openAt(workTree, gitDir string) (*Repo, error) {
+	r := &Repo{WorkTree: workTree, GitDir: gitDir, CommonDir: gitDir}
+	if data, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		common := strings.TrimSpace(string(data))
+		if !filepath.IsAbs(common) {
+			common = filepath.Join(gitDir, common)
+		}
+		r.CommonDir = filepath.Clean(common)
+	}
+
+	cfg, err := readConfig(filepath.Join(r.CommonDir, "config"))
+	if err != nil {
+		return nil, ErrNotRepo
+	}
+	r.config = cfg
+	for key := range cfg {
+		if ext, ok := strings.CutPrefix(key, "extensions."); ok && !harmlessExtensions[ext] {
+			return nil, ErrUnsupported
+		}
+	}
+	if r.boolConfig("core.bare", false) {
+		return nil, ErrNotRepo
+	}
+	if r.boolConfig("extensions.worktreeconfig", false) {
+		if wt, err := readConfig(filepath.Join(gitDir, "config.worktree")); err == nil {
+			for k, v := range wt {
+				r.config[k] = v
+			}
+		}
+	}
+	// Included files, conditional or not, could change any of the above.
+	for key := range r.config {
+		if key == "include.path" || strings.HasPrefix(key, "includeif.") && strings.HasSuffix(key, ".path") {
+			return nil, ErrUnsupported
+		}
+	}
+	if r.boolConfig("core.sparsecheckout", false) || r.config["core.worktree"] != "" {
+		return nil, ErrUnsupported
+	}
+
+	r.objects = newObjectStore(filepath.Join(r.CommonDir, "objects"))
+	return r, nil
+}
+
+// Close releases any open pack files.
+func (r *Repo) Close() error {
+	return r.objects.close()
+}
+
+// Config returns a value by its lowercased "section.subsection.key" name.
+// Subsection names keep their case, as in git.
+func (r *Repo) Config(key string) (string, bool) {
+	v, ok := r.config[key]
+	return v, ok
+}
+
+func (r *Repo) boolConfig(key string, def bool) bool {
+	v, ok := r.config[key]
+	if !ok {
+		return def
+	}
+	switch strings.ToLower(v) {
+	case "", "true", "yes", "on", "1":
+		return true
+	case "false", "no", "off", "0":
+		return false
+	}
+	return def
+}
+
+// readConfig parses git's INI dialect into a flat map. Only the last value
+// of a multi-valued key is kept; remote.*.fetch is joined with newlines.
+func readConfig(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	cfg := make(map[string]string)
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		for strings.HasSuffix(line, "\\") && scanner.Scan() {
+			line = strings.TrimSuffix(line, "\\") + strings.TrimSpace(scanner.Text())
+		}
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' {
+			end := strings.LastIndexByte(line, ']')
+			if end < 0 {
+				continue
+			}
+			section = parseSectionHeader(line[1:end])
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(line, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !hasValue {
+			value = "true"
+		} else {
+			value = unquoteConfigValue(strings.TrimSpace(value))
+		}
+		key := section + "." + name
+		if prev, ok := cfg[key]; ok && strings.HasSuffix(key, ".fetch") {
+			value = prev + "\n" + value
+		}
+		cfg[key] = value
+	}
+	return cfg, scanner.Err()
+}
+
+func parseSectionHeader(h string) string {
+	if i := strings.IndexByte(h, '"'); i >= 0 {
+		name := strings.ToLower(strings.TrimSpace(h[:i]))
+		sub := strings.TrimSuffix(h[i+1:], "\"")
+		sub = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(sub)
+		return name + "." + sub
+	}
+	// Deprecated [section.subsection] form: subsection is lowercased too.
+	return strings.ToLower(strings.TrimSpace(h))
+}
+
+func unquoteConfigValue(v string) string {
+	var b strings.Builder
+	inQuote := false
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		switch {
+		case c == '"':
+			inQuote = !inQuote
+		case c == '\\' && i+1 < len(v):
+			i++
+			switch v[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(v[i])
+			}
+		case (c == '#' || c == ';') && !inQuote:
+			return strings.TrimSpace(b.String())
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// Head returns the checked-out branch name ("" when detached) and the commit
+// HEAD points at (zero on an unborn branch).
+func (r *Repo) Head() (branch string, oid Hash, err error) {
+	data, err := os.ReadFile(filepath.Join(r.GitDir, "HEAD"))
+	if err != nil {
+		return "", Hash{}, err
+	}
+	head := strings.TrimSpace(string(data))
+	if target, ok := strings.CutPrefix(head, "ref: "); ok {
+		branch = strings.TrimPrefix(target, "refs/heads/")
+		oid, err = r.ResolveRef(target)
+		if errors.Is(err, ErrNotFound) {
+			return branch, Hash{}, nil
+		}
+		return branch, oid, err
+	}
+	h, ok := parseHash(head)
+	if !ok {
+		return "", Hash{}, ErrUnsupported
+	}
+	return "", h, nil
+}
+
+// ResolveRef follows a ref (and any symbolic refs it points at) to an object.
+func (r *Repo) ResolveRef(name string) (Hash, error) {
+	for depth := 0; depth < 5; depth++ {
+		value, err := r.readRef(name)
+		if err != nil {
+			return Hash{}, err
+		}
+		if target, ok := strings.CutPrefix(value, "ref: "); ok {
+			name = target
+			continue
+		}
+		h, ok := parseHash(value)
+		if !ok {
+			return Hash{}, fmt.Errorf("gitrepo: bad ref %s", name)
+		}
+		return h, nil
+	}
+	return Hash{}, fmt.Errorf("gitrepo: symbolic ref loop at %s", name)
+}
+
+func (r *Repo) readRef(name string) (string, error) {
+	dir := r.CommonDir
+	if !strings.HasPrefix(name, "refs/") || strings.HasPrefix(name, "refs/worktree/") ||
+		strings.HasPrefix(name, "refs/bisect/") {
+		dir = r.GitDir
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name))); err == nil {
+		return strings.TrimSpace(string(data)), nil
+	}
+	return r.packedRef(name)
+}
+
+func (r *Repo) packedRef(name string) (string, error) {
+	f, err := os.Open(filepath.Join(r.CommonDir, "packed-refs"))
+	if err != nil {
+		return "", ErrNotFound
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || line[0] == '#' || line[0] == '^' {
+			continue
+		}
+		oid, ref, ok := strings.Cut(line, " ")
+		if ok && ref == name {
+			return oid, nil
+		}
+	}
+	return "", ErrNotFound
+}
+
+// HasStash reports whether refs/stash exists.
+func (r *Repo) HasStash() bool {
+	_, err := r.ResolveRef("refs/stash")
+	return err == nil
+}
+
+// Upstream returns the remote-tracking ref configured for branch, following
+// the remote's fetch refspecs the way git does.
+func (r *Repo) Upstream(branch string) (string, bool) {
+	remote := r.config["branch."+branch+".remote"]
+	merge := r.config["branch."+branch+".merge"]
+	if remote == "" || merge == "" {
+		return "", false
+	}
+	if remote == "." {
+		return merge, true
+	}
+	for _, spec := range strings.Split(r.config["remote."+remote+".fetch"], "\n") {
+		src, dst, ok := strings.Cut(strings.TrimPrefix(spec, "+"), ":")
+		if !ok {
+			continue
+		}
+		if ref, ok := mapRefspec(src, dst, merge); ok {
+			return ref, true
+		}
+	}
+	return "", false
+}
+
+func mapRefspec(src, dst, ref string) (string, bool) {
+	i := strings.IndexByte(src, '*')
+	if i < 0 {
+		return dst, src == ref
+	}
+	prefix, suffix := src[:i], src[i+1:]
+	if !strings.HasPrefix(ref, prefix) || !strings.HasSuffix(ref, suffix) ||
+		len(ref) < len(prefix)+len(suffix) {
+		return "", false
+	}
+	middle := ref[len(prefix) : len(ref)-len(suffix)]
+	return strings.Replace(dst, "*", middle, 1), true
+}
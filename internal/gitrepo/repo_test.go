@@ -0,0 +1,130 @@
+package gitrepo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	text := `# a comment
+[core]
+	bare = false
+	FileMode
+[remote "origin"]
+	url = "git@example.com:ann/x.git" ; trailing comment
+	fetch = +refs/heads/*:refs/remotes/origin/*
+	fetch = +refs/tags/*:refs/tags/*
+[branch "Feature/X"]
+	merge = refs/heads/Feature/X
+[Section.Sub]
+	key = a\tb # trailing comment
+[alias]
+	long = one \
+two
+[user]
+	name = "Ann \"A\" Lee"
+	url = "https://example.com/#anchor"
+`
+	if err := os.WriteFile(path, []byte(text), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := readConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]string{
+		"core.bare":              "false",
+		"core.filemode":          "true",
+		"remote.origin.url":      "git@example.com:ann/x.git",
+		"remote.origin.fetch":    "+refs/heads/*:refs/remotes/origin/*\n+refs/tags/*:refs/tags/*",
+		"branch.Feature/X.merge": "refs/heads/Feature/X",
+		"section.sub.key":        "a\tb",
+		"alias.long":             "one two",
+		"user.name":              `Ann "A" Lee`,
+		"user.url":               "https://example.com/#anchor",
+	}
+	for key, want := range cases {
+		if got, ok := cfg[key]; !ok || got != want {
+			t.Errorf("%s = %q (%v), want %q", key, got, ok, want)
+		}
+	}
+	if _, ok := cfg["branch.feature/x.merge"]; ok {
+		t.Error("subsection name was lowercased")
+	}
+}
+
+func TestParseSectionHeader(t *testing.T) {
+	cases := map[string]string{
+		`core`:                       "core",
+		` Core `:                     "core",
+		`remote "origin"`:            "remote.origin",
+		`Branch "Feature/X"`:         "branch.Feature/X",
+		`branch "a\"b\\c"`:           `branch.a"b\c`,
+		`Section.Sub`:                "section.sub",
+		`includeIf "gitdir:~/work/"`: "includeif.gitdir:~/work/",
+	}
+	for in, want := range cases {
+		if got := parseSectionHeader(in); got != want {
+			t.Errorf("parseSectionHeader(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMapRefspec(t *testing.T) {
+	cases := []struct {
+		src, dst, ref string
+		want          string
+		ok            bool
+	}{
+		{"refs/heads/*", "refs/remotes/origin/*", "refs/heads/main", "refs/remotes/origin/main", true},
+		{"refs/heads/*", "refs/remotes/origin/*", "refs/heads/feat/x", "refs/remotes/origin/feat/x", true},
+		{"refs/heads/main", "refs/remotes/origin/trunk", "refs/heads/main", "refs/remotes/origin/trunk", true},
+		{"refs/heads/main", "refs/remotes/origin/main", "refs/heads/dev", "", false},
+		{"refs/heads/feat/*", "refs/remotes/origin/f/*", "refs/heads/main", "", false},
+		{"refs/heads/*-wip", "refs/remotes/origin/wip/*", "refs/heads/a-wip", "refs/remotes/origin/wip/a", true},
+		{"refs/heads/a*a", "refs/remotes/origin/*", "refs/heads/a", "", false},
+	}
+	for _, tc := range cases {
+		got, ok := mapRefspec(tc.src, tc.dst, tc.ref)
+		if ok != tc.ok || ok && got != tc.want {
+			t.Errorf("mapRefspec(%q, %q, %q) = %q, %v; want %q, %v", tc.src, tc.dst, tc.ref, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestOpenRejectsIncludes(t *testing.T) {
+	cases := []struct {
+		name   string
+		config string
+		err    error
+	}{
+		{"plain", "[core]\n\tbare = false\n", nil},
+		{"include", "[include]\n\tpath = ~/extra.gitconfig\n", ErrUnsupported},
+		{"includeIf", "[includeIf \"gitdir:~/work/\"]\n\tpath = ~/work.gitconfig\n", ErrUnsupported},
+		{"includeIf onbranch", "[includeIf \"onbranch:main\"]\n\tpath = main.gitconfig\n", ErrUnsupported},
+		{"unknown extension", "[extensions]\n\tobjectFormat = sha256\n", ErrUnsupported},
+		{"bare", "[core]\n\tbare = true\n", ErrNotRepo},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			gitDir := filepath.Join(dir, ".git")
+			if err := os.Mkdir(gitDir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(gitDir, "config"), []byte(tc.config), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			r, err := Open(dir)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("Open = %v, want %v", err, tc.err)
+			}
+			if r != nil {
+				_ = r.Close()
+			}
+		})
+	}
+}
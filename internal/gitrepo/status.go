@@ -0,0 +1,382 @@
+package gitrepo
+
+import (
+	"container/heap"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"io"
+	"math/bits"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// Abbrev shortens h the way git's core.abbrev=auto does, scaling with the
+// approximate object count (without git's extra uniqueness check).
+func (r *Repo) Abbrev(h Hash) string {
+	n := 7
+	if v, ok := r.config["core.abbrev"]; ok {
+		if i, err := strconv.Atoi(v); err == nil && i >= 4 && i <= 40 {
+			n = i
+		}
+	} else if count := r.objects.count(); count > 0 {
+		if l := (bits.Len(uint(count)) + 1 + 1) / 2; l > n {
+			n = l
+		}
+	}
+	return h.String()[:n]
+}
+
+// Without a valid cache-tree (right after `git add`, or an index written
+// without one), comparing the index with HEAD means reading every tree in
+// HEAD. Past this many index entries that is git's job: it costs more than
+// the time budget allows.
+var maxFlatten = 5000
+
+// Walking further than this means the histories diverged wildly; let git
+// (and its commit-graph) answer instead.
+const maxWalk = 20000
+
+const (
+	sideLeft  uint8 = 1
+	sideRight uint8 = 2
+	sideBoth        = sideLeft | sideRight
+)
+
+type walkItem struct {
+	oid  Hash
+	time int64
+}
+
+type walkQueue []walkItem
+
+func (q walkQueue) Len() int           { return len(q) }
+func (q walkQueue) Less(i, j int) bool { return q[i].time > q[j].time }
+func (q walkQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *walkQueue) Push(x any)        { *q = append(*q, x.(walkItem)) }
+func (q *walkQueue) Pop() any {
+	old := *q
+	it := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return it
+}
+
+// AheadBehind counts commits reachable from local but not upstream (ahead)
+// and the reverse (behind), walking both histories newest-first until every
+// pending commit is known to be shared, as `git rev-list --left-right` does.
+func (r *Repo) AheadBehind(ctx context.Context, local, upstream Hash) (ahead, behind int, err error) {
+	if local == upstream {
+		return 0, 0, nil
+	}
+
+	flags := make(map[Hash]uint8)
+	commits := make(map[Hash]commit)
+	queued := make(map[Hash]bool)
+	q := &walkQueue{}
+	pending := 0 // queued commits not yet known to be shared
+
+	push := func(h Hash) error {
+		c, ok := commits[h]
+		if !ok {
+			var err error
+			if c, err = r.readCommit(h); err != nil {
+				return err
+			}
+			commits[h] = c
+		}
+		heap.Push(q, walkItem{oid: h, time: c.time})
+		queued[h] = true
+		if flags[h] != sideBoth {
+			pending++
+		}
+		return nil
+	}
+
+	flags[local], flags[upstream] = sideLeft, sideRight
+	if err := push(local); err != nil {
+		return 0, 0, err
+	}
+	if err := push(upstream); err != nil {
+		return 0, 0, err
+	}
+
+	for pending > 0 && q.Len() > 0 {
+		if len(commits) > maxWalk {
+			return 0, 0, ErrUnsupported
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, 0, err
+		}
+		it := heap.Pop(q).(walkItem)
+		delete(queued, it.oid)
+		f := flags[it.oid]
+		if f != sideBoth {
+			pending--
+		}
+		for _, p := range commits[it.oid].parents {
+			old := flags[p]
+			if old|f == old {
+				continue
+			}
+			flags[p] = old | f
+			if queued[p] {
+				if old|f == sideBoth {
+					pending--
+				}
+				continue
+			}
+			if err := push(p); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+
+	for _, f := range flags {
+		switch f {
+		case sideLeft:
+			ahead++
+		case sideRight:
+			behind++
+		}
+	}
+	return ahead, behind, nil
+}
+
+// Dirty reports whether git status would list any tracked change: the index
+// differs from HEAD, or the work tree differs from the index. Work tree
+// entries are compared by the stat data cached in the index and hashed only
+// when that data is stale or racy.
+func (r *Repo) Dirty(ctx context.Context, head Hash) (bool, error) {
+	idx, err := r.readIndex()
+	if err != nil {
+		return false, err
+	}
+	if staged, err := r.stagedChanges(ctx, idx, head); err != nil || staged {
+		return staged, err
+	}
+	return r.worktreeChanges(ctx, idx)
+}
+
+func (r *Repo) stagedChanges(ctx context.Context, idx *index, head Hash) (bool, error) {
+	for _, e := range idx.entries {
+		if e.stage() != 0 || e.extFlags&flagIntentToAdd != 0 {
+			return true, nil
+		}
+	}
+	if head.IsZero() {
+		return len(idx.entries) > 0, nil
+	}
+	c, err := r.readCommit(head)
+	if err != nil {
+		return false, err
+	}
+	if idx.hasTree {
+		return idx.tree != c.tree, nil
+	}
+	if len(idx.entries) > maxFlatten {
+		return false, ErrUnsupported
+	}
+
+	tree := make(map[string]treeEntry, len(idx.entries))
+	if err := r.flattenTree(ctx, c.tree, "", tree); err != nil {
+		return false, err
+	}
+	if len(tree) != len(idx.entries) {
+		return true, nil
+	}
+	for _, e := range idx.entries {
+		te, ok := tree[e.path]
+		if !ok || te.oid != e.oid || te.mode != e.mode {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *Repo) flattenTree(ctx context.Context, h Hash, prefix string, out map[string]treeEntry) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	entries, err := r.readTree(h)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		p := path.Join(prefix, e.name)
+		if e.mode == 0o40000 {
+			if err := r.flattenTree(ctx, e.oid, p, out); err != nil {
+				return err
+			}
+			continue
+		}
+		out[p] = e
+	}
+	return nil
+}
+
+func (r *Repo) worktreeChanges(ctx context.Context, idx *index) (bool, error) {
+	if len(idx.entries) == 0 {
+		return false, nil
+	}
+	filemode := r.boolConfig("core.filemode", true)
+	if !r.boolConfig("core.symlinks", true) {
+		return false, ErrUnsupported
+	}
+	// Content filters (eol, clean/smudge) make a hash mismatch meaningless.
+	filtered := r.config["core.autocrlf"] != "" && r.config["core.autocrlf"] != "false"
+	if _, err := os.Stat(filepath.Join(r.CommonDir, "info", "attributes")); err == nil {
+		filtered = true
+	}
+	for _, e := range idx.entries {
+		if path.Base(e.path) == ".gitattributes" {
+			filtered = true
+			break
+		}
+	}
+
+	var dirty atomic.Bool
+	var firstErr error
+	var errOnce sync.Once
+	var wg sync.WaitGroup
+	workers := runtime.NumCPU()
+	if workers > 8 {
+		workers = 8
+	}
+	chunk := (len(idx.entries) + workers - 1) / workers
+	for start := 0; start < len(idx.entries); start += chunk {
+		end := start + chunk
+		if end > len(idx.entries) {
+			end = len(idx.entries)
+		}
+		wg.Add(1)
+		go func(entries []indexEntry) {
+			defer wg.Done()
+			for i, e := range entries {
+				if dirty.Load() {
+					return
+				}
+				if i%256 == 0 && ctx.Err() != nil {
+					errOnce.Do(func() { firstErr = ctx.Err() })
+					return
+				}
+				changed, err := r.entryChanged(e, idx, filemode, filtered)
+				if err != nil {
+					errOnce.Do(func() { firstErr = err })
+					return
+				}
+				if changed {
+					dirty.Store(true)
+					return
+				}
+			}
+		}(idx.entries[start:end])
+	}
+	wg.Wait()
+
+	if dirty.Load() {
+		return true, nil
+	}
+	return false, firstErr
+}
+
+func (r *Repo) entryChanged(e indexEntry, idx *index, filemode, filtered bool) (bool, error) {
+	if e.flags&flagAssumeValid != 0 {
+		return false, nil
+	}
+	p := filepath.Join(r.WorkTree, filepath.FromSlash(e.path))
+	fi, err := os.Lstat(p)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.ENOTDIR) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	switch e.mode & 0o170000 {
+	case 0o160000:
+		return submoduleMoved(p, fi, e.oid), nil
+	case 0o120000:
+		if fi.Mode()&os.ModeSymlink == 0 {
+			return true, nil
+		}
+	default:
+		if !fi.Mode().IsRegular() {
+			return true, nil
+		}
+		if filemode && (fi.Mode()&0o111 != 0) != (e.mode&0o111 != 0) {
+			return true, nil
+		}
+	}
+	if uint32(fi.Size()) != e.size {
+		return true, nil
+	}
+
+	mt := fi.ModTime()
+	sameMtime := uint32(mt.Unix()) == e.mtimeSec &&
+		(e.mtimeNsec == 0 || uint32(mt.Nanosecond()) == e.mtimeNsec)
+	// An entry written in the same instant as the index may have been
+	// modified again without its mtime changing: "racy git".
+	entryTime := int64(e.mtimeSec)*1e9 + int64(e.mtimeNsec)
+	racy := entryTime >= idx.mtime.UnixNano()
+	if sameMtime && !racy {
+		return false, nil
+	}
+
+	h, err := hashWorktreeFile(p, fi)
+	if err != nil {
+		return false, err
+	}
+	if h != e.oid && filtered {
+		return false, ErrUnsupported
+	}
+	return h != e.oid, nil
+}
+
+// submoduleMoved reports whether a checked-out submodule's HEAD differs from
+// the commit recorded in the index. Uninitialized submodules are clean.
+func submoduleMoved(dir string, fi os.FileInfo, recorded Hash) bool {
+	if !fi.IsDir() {
+		return true
+	}
+	gitDir, ok := gitDirAt(dir)
+	if !ok {
+		return false
+	}
+	sub, err := openAt(dir, gitDir)
+	if err != nil {
+		return false
+	}
+	defer func() { _ = sub.Close() }()
+	_, head, err := sub.Head()
+	return err == nil && head != recorded
+}
+
+func hashWorktreeFile(p string, fi os.FileInfo) (Hash, error) {
+	var h Hash
+	sum := sha1.New()
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(p)
+		if err != nil {
+			return h, err
+		}
+		_, _ = io.WriteString(sum, "blob "+strconv.Itoa(len(target))+"\x00"+target)
+	} else {
+		f, err := os.Open(p)
+		if err != nil {
+			return h, err
+		}
+		defer func() { _ = f.Close() }()
+		_, _ = io.WriteString(sum, "blob "+strconv.FormatInt(fi.Size(), 10)+"\x00")
+		if _, err := io.Copy(sum, f); err != nil {
+			return h, err
+		}
+	}
+	copy(h[:], sum.Sum(nil))
+	return h, nil
+}
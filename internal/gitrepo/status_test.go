@@ -0,0 +1,74 @@
+package gitrepo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func gitCmd(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_CONFIG_GLOBAL="+os.DevNull,
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.com",
+		"GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@example.com",
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
+
+// TestDirtyWithoutCacheTree covers an index whose cache-tree was invalidated
+// by `git add`: small indexes are compared with HEAD's trees, large ones are
+// left to git.
+func TestDirtyWithoutCacheTree(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	gitCmd(t, dir, "init", "-q", "-b", "main")
+	for _, name := range []string{"a", "b", "c"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gitCmd(t, dir, "add", ".")
+	gitCmd(t, dir, "commit", "-q", "-m", "init")
+	// Re-adding an unchanged file drops the root cache-tree entry but
+	// leaves the index equal to HEAD.
+	gitCmd(t, dir, "update-index", "--force-remove", "a")
+	gitCmd(t, dir, "add", "a")
+
+	r, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = r.Close() }()
+	idx, err := r.readIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.hasTree {
+		t.Fatal("index still has a valid cache-tree")
+	}
+	_, head, err := r.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dirty, err := r.Dirty(context.Background(), head); err != nil || dirty {
+		t.Errorf("Dirty = %v, %v; want clean", dirty, err)
+	}
+
+	defer func(n int) { maxFlatten = n }(maxFlatten)
+	maxFlatten = 2
+	if _, err := r.Dirty(context.Background(), head); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Dirty past maxFlatten = %v, want ErrUnsupported", err)
+	}
+}
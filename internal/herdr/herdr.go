@@ -33,7 +33,7 @@ func Status(raw map[string]any) (customStatus, displayAgent string) {
 		parts = append(parts, c.Render().Text)
 	}
 	if l, ok := ratelimit.Parse(raw); ok {
-		parts = append(parts, l.Render(false).Text)
+		parts = append(parts, l.Render(nil).Text)
 	}
 	return capRunes(strings.Join(parts, " "), maxStatusRunes), compactModel(model.Parse(raw).DisplayName)
 }
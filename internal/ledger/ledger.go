@@ -0,0 +1,259 @@
+// Package ledger keeps a local history of Claude sessions (cost, duration,
+// lines, model) so spend can be totalled across sessions. Each session is
+// one small JSON file under $XDG_STATE_HOME/ccsl/sessions, rewritten only
+// when its numbers change.
+package ledger
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hergert/ccsl/internal/xdg"
+)
+
+const dayLayout = "2006-01-02"
+
+type Session struct {
+	SessionID    string    `json:"session_id"`
+	ProjectDir   string    `json:"project_dir"`
+	Model        string    `json:"model"`
+	CostUSD      float64   `json:"cost_usd"`
+	Estimated    bool      `json:"estimated,omitempty"` // CostUSD is worked out from token counts
+	DurationMS   float64   `json:"duration_ms"`
+	LinesAdded   int       `json:"lines_added"`
+	LinesRemoved int       `json:"lines_removed"`
+	Updated      time.Time `json:"updated"`
+	// Cost attributed to each local calendar day, so a session running past
+	// midnight splits across both days.
+	Days map[string]float64 `json:"days"`
+}
+
+// Dir is where session records live.
+func Dir() string {
+	return filepath.Join(xdg.StateDir(), "sessions")
+}
+
+// Enabled is false when CCSL_LEDGER=0.
+func Enabled() bool {
+	return os.Getenv("CCSL_LEDGER") != "0"
+}
+
+// Record folds the statusline JSON into the session's ledger entry. The
+// cost growth since the last record is attributed to now's day. When Claude
+// Code reports no cost (gateways, Bedrock, Vertex), estimate supplies one if
+// non-nil and the entry is flagged Estimated; an estimate that can't be made
+// this time keeps the last one. No-op without a session_id or when nothing
+// changed.
+func Record(raw map[string]any, now time.Time, estimate func() (float64, bool)) error {
+	id, _ := raw["session_id"].(string)
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return nil
+	}
+
+	cur := Session{SessionID: id}
+	if ws, ok := raw["workspace"].(map[string]any); ok {
+		cur.ProjectDir, _ = ws["project_dir"].(string)
+	}
+	if m, ok := raw["model"].(map[string]any); ok {
+		if cur.Model, _ = m["id"].(string); cur.Model == "" {
+			cur.Model, _ = m["display_name"].(string)
+		}
+	}
+	if c, ok := raw["cost"].(map[string]any); ok {
+		cur.CostUSD, _ = c["total_cost_usd"].(float64)
+		cur.DurationMS, _ = c["total_duration_ms"].(float64)
+		added, _ := c["total_lines_added"].(float64)
+		removed, _ := c["total_lines_removed"].(float64)
+		cur.LinesAdded, cur.LinesRemoved = int(added), int(removed)
+	}
+
+	path := filepath.Join(Dir(), id+".json")
+	prev, _ := readSession(path)
+	if cur.CostUSD == 0 && estimate != nil {
+		if usd, ok := estimate(); ok {
+			cur.CostUSD, cur.Estimated = usd, true
+		} else if prev.Estimated {
+			cur.CostUSD, cur.Estimated = prev.CostUSD, true
+		}
+	}
+	if prev.CostUSD == cur.CostUSD && prev.Estimated == cur.Estimated && prev.DurationMS == cur.DurationMS &&
+		prev.LinesAdded == cur.LinesAdded && prev.LinesRemoved == cur.LinesRemoved &&
+		prev.Model == cur.Model && prev.ProjectDir == cur.ProjectDir {
+		return nil
+	}
+
+	cur.Days = prev.Days
+	if cur.Days == nil {
+		cur.Days = make(map[string]float64)
+	}
+	delta := cur.CostUSD - prev.CostUSD
+	if delta < 0 {
+		delta = cur.CostUSD // counters restarted (e.g. a resumed session)
+	}
+	if delta > 0 {
+		cur.Days[now.Format(dayLayout)] += delta
+	}
+	cur.Updated = now
+
+	data, err := json.Marshal(cur)
+	if err != nil {
+		return err
+	}
+	return xdg.WriteFileAtomic(path, data)
+}
+
+func readSession(path string) (Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Session{}, err
+	}
+	var s Session
+	err = json.Unmarshal(data, &s)
+	return s, err
+}
+
+// Load returns every session updated at or after since. Files are filtered
+// by mtime first so old history costs a stat, not a read.
+func Load(since time.Time) ([]Session, error) {
+	entries, err := os.ReadDir(Dir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []Session
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		if fi, err := e.Info(); err != nil || fi.ModTime().Before(since) {
+			continue
+		}
+		s, err := readSession(filepath.Join(Dir(), e.Name()))
+		if err != nil || s.Updated.Before(since) {
+			continue
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// Totals is spend summed over calendar periods ending now.
+type Totals struct {
+	Today float64
+	Week  float64 // since Monday
+	Month float64
+}
+
+// StartOfWeek is local midnight on the Monday of now's week.
+func StartOfWeek(now time.Time) time.Time {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// StartOfMonth is local midnight on the first of now's month.
+func StartOfMonth(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+}
+
+// Since is the earliest instant Sum needs, for passing to Load.
+func Since(now time.Time) time.Time {
+	if w, m := StartOfWeek(now), StartOfMonth(now); w.Before(m) {
+		return w
+	}
+	return StartOfMonth(now)
+}
+
+// Sum totals the per-day costs of sessions for today, this week and this
+// month.
+func Sum(sessions []Session, now time.Time) Totals {
+	today := now.Format(dayLayout)
+	week := StartOfWeek(now).Format(dayLayout)
+	month := StartOfMonth(now).Format(dayLayout)
+
+	var t Totals
+	for _, s := range sessions {
+		for day, cost := range s.Days {
+			if day > today {
+				continue
+			}
+			if day == today {
+				t.Today += cost
+			}
+			if day >= week {
+				t.Week += cost
+			}
+			if day >= month {
+				t.Month += cost
+			}
+		}
+	}
+	return t
+}
+
+// ProjectDay totals today's cost of sessions in projectDir.
+func ProjectDay(sessions []Session, projectDir string, now time.Time) float64 {
+	today := now.Format(dayLayout)
+	var total float64
+	for _, s := range sessions {
+		if s.ProjectDir == projectDir {
+			total += s.Days[today]
+		}
+	}
+	return total
+}
+
+// Row is one line of `ccsl report`: spend for a day, project and model.
+type Row struct {
+	Day        string
+	ProjectDir string
+	Model      string
+	Sessions   int
+	CostUSD    float64
+	Estimated  bool // some of CostUSD is estimated from token counts
+}
+
+// Rows groups sessions' per-day costs by day, project and model, newest day
+// first and most expensive first within a day. Days before since are left out.
+func Rows(sessions []Session, since time.Time) []Row {
+	from := since.Format(dayLayout)
+	type groupKey struct{ day, project, model string }
+	groups := make(map[groupKey]*Row)
+	for _, s := range sessions {
+		for day, cost := range s.Days {
+			if day < from {
+				continue
+			}
+			k := groupKey{day, s.ProjectDir, s.Model}
+			r, ok := groups[k]
+			if !ok {
+				r = &Row{Day: day, ProjectDir: s.ProjectDir, Model: s.Model}
+				groups[k] = r
+			}
+			r.Sessions++
+			r.CostUSD += cost
+			r.Estimated = r.Estimated || s.Estimated
+		}
+	}
+
+	rows := make([]Row, 0, len(groups))
+	for _, r := range groups {
+		rows = append(rows, *r)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Day != rows[j].Day {
+			return rows[i].Day > rows[j].Day
+		}
+		if rows[i].CostUSD != rows[j].CostUSD {
+			return rows[i].CostUSD > rows[j].CostUSD
+		}
+		return rows[i].ProjectDir+rows[i].Model < rows[j].ProjectDir+rows[j].Model
+	})
+	return rows
+}
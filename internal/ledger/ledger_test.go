@@ -0,0 +1,132 @@
+package ledger
+
+import (
+	"math"
+	"os"
+	"testing"
+	"time"
+)
+
+func statusJSON(session, project, model string, cost float64) map[string]any {
+	return map[string]any{
+		"session_id": session,
+		"model":      map[string]any{"id": model},
+		"workspace":  map[string]any{"project_dir": project},
+		"cost":       map[string]any{"total_cost_usd": cost, "total_duration_ms": cost * 1000},
+	}
+}
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestRecordSplitsCostAcrossDays(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	mon := time.Date(2026, 10, 12, 23, 0, 0, 0, time.Local)
+	tue := mon.Add(2 * time.Hour)
+
+	for _, step := range []struct {
+		at   time.Time
+		cost float64
+	}{{mon, 1.0}, {mon, 1.0}, {mon.Add(time.Minute), 1.5}, {tue, 4.0}} {
+		if err := Record(statusJSON("s1", "/src/app", "opus", step.cost), step.at, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sessions, err := Load(time.Time{})
+	if err != nil || len(sessions) != 1 {
+		t.Fatalf("Load = %v, %v; want one session", sessions, err)
+	}
+	days := sessions[0].Days
+	if !near(days["2026-10-12"], 1.5) || !near(days["2026-10-13"], 2.5) {
+		t.Errorf("Days = %v, want 1.5 on the 12th and 2.5 on the 13th", days)
+	}
+}
+
+func TestRecordEstimatesUnreportedCost(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	now := time.Date(2026, 10, 12, 10, 0, 0, 0, time.Local)
+	raw := statusJSON("s1", "/src/app", "claude-sonnet-4-5@20250929", 0)
+
+	for i, est := range []struct {
+		usd float64
+		ok  bool
+	}{{0.5, true}, {0, false}, {1.25, true}} {
+		raw["cost"].(map[string]any)["total_duration_ms"] = float64(i)
+		estimate := func() (float64, bool) { return est.usd, est.ok }
+		if err := Record(raw, now.Add(time.Duration(i)*time.Minute), estimate); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sessions, err := Load(time.Time{})
+	if err != nil || len(sessions) != 1 {
+		t.Fatalf("Load = %v, %v; want one session", sessions, err)
+	}
+	s := sessions[0]
+	// The failed estimate kept $0.50 rather than counting $1.25 twice.
+	if !s.Estimated || !near(s.CostUSD, 1.25) || !near(s.Days["2026-10-12"], 1.25) {
+		t.Errorf("session = %+v, want an estimated $1.25", s)
+	}
+
+	// A reported cost is taken as is, without asking for an estimate.
+	called := false
+	raw = statusJSON("s2", "/src/app", "opus", 2)
+	if err := Record(raw, now, func() (float64, bool) { called = true; return 9, true }); err != nil {
+		t.Fatal(err)
+	}
+	if called {
+		t.Error("estimate called despite a reported cost")
+	}
+}
+
+func TestRecordIgnoresAnonymousSessions(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	if err := Record(statusJSON("", "/src/app", "opus", 1), time.Now(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(Dir()); !os.IsNotExist(err) {
+		t.Errorf("expected no ledger written, stat err = %v", err)
+	}
+}
+
+func TestSumPeriods(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.Local) // a Friday
+	sessions := []Session{
+		{Days: map[string]float64{"2026-10-16": 2, "2026-10-12": 3}},
+		{Days: map[string]float64{"2026-10-11": 5, "2026-09-30": 7}},
+	}
+	got := Sum(sessions, now)
+	want := Totals{Today: 2, Week: 5, Month: 10}
+	if got != want {
+		t.Errorf("Sum = %+v, want %+v", got, want)
+	}
+}
+
+func TestStartOfWeekIsMonday(t *testing.T) {
+	sun := time.Date(2026, 10, 18, 9, 0, 0, 0, time.Local)
+	if got := StartOfWeek(sun); got.Weekday() != time.Monday || got.Day() != 12 {
+		t.Errorf("StartOfWeek(Sunday 18th) = %v, want Monday 12th", got)
+	}
+}
+
+func TestRowsGroupByDayProjectModel(t *testing.T) {
+	sessions := []Session{
+		{ProjectDir: "/a", Model: "opus", Days: map[string]float64{"2026-10-16": 1, "2026-10-15": 2}},
+		{ProjectDir: "/a", Model: "opus", Days: map[string]float64{"2026-10-16": 3}},
+		{ProjectDir: "/b", Model: "sonnet", Days: map[string]float64{"2026-10-16": 0.5, "2026-09-01": 9}},
+	}
+	rows := Rows(sessions, time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local))
+	want := []Row{
+		{Day: "2026-10-16", ProjectDir: "/a", Model: "opus", Sessions: 2, CostUSD: 4},
+		{Day: "2026-10-16", ProjectDir: "/b", Model: "sonnet", Sessions: 1, CostUSD: 0.5},
+		{Day: "2026-10-15", ProjectDir: "/a", Model: "opus", Sessions: 1, CostUSD: 2},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("Rows = %+v, want %+v", rows, want)
+	}
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Errorf("row %d = %+v, want %+v", i, rows[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package palette
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Depth is how many colors the terminal can show.
+type Depth int
+
+const (
+	Depth16 Depth = iota
+	Depth256
+	DepthTrue
+)
+
+// DetectDepth reads the terminal's color support from COLORTERM and TERM.
+// An unset TERM (Claude Code may not pass one) is taken as 256 colors.
+func DetectDepth(colorterm, term string) Depth {
+	switch {
+	case colorterm == "truecolor" || colorterm == "24bit":
+		return DepthTrue
+	case term == "" || strings.Contains(term, "256color"):
+		return Depth256
+	}
+	return Depth16
+}
+
+// forcedDepth reads FORCE_COLOR's level, as supports-color and chalk do:
+// 1 is 16 colors, 2 is 256, 3 is truecolor.
+func forcedDepth(v string) (Depth, bool) {
+	switch v {
+	case "1":
+		return Depth16, true
+	case "2":
+		return Depth256, true
+	case "3":
+		return DepthTrue, true
+	}
+	return 0, false
+}
+
+func parseDepth(s string) (Depth, bool) {
+	switch s {
+	case "truecolor", "24bit":
+		return DepthTrue, true
+	case "256":
+		return Depth256, true
+	case "16":
+		return Depth16, true
+	}
+	return 0, false
+}
+
+// color is an xterm palette index (0-255) or, when rgb is set, a 24-bit
+// color.
+type color struct {
+	rgb     bool
+	index   uint8
+	r, g, b uint8
+}
+
+var basicNames = []string{
+	"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white",
+	"bright-black", "bright-red", "bright-green", "bright-yellow",
+	"bright-blue", "bright-magenta", "bright-cyan", "bright-white",
+}
+
+// xterm's default RGB values for the 16 basic colors, used to pick the
+// nearest one when degrading.
+var basicRGB = [16][3]uint8{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// parseColor reads "#rrggbb", "#rgb", an index "0"-"255" or a basic color
+// name ("gray"/"grey" mean bright-black).
+func parseColor(s string) (color, error) {
+	if hex, ok := strings.CutPrefix(s, "#"); ok {
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if len(hex) != 6 || err != nil {
+			return color{}, fmt.Errorf("invalid hex color %q", s)
+		}
+		return color{rgb: true, r: uint8(v >> 16), g: uint8(v >> 8), b: uint8(v)}, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 0 || n > 255 {
+			return color{}, fmt.Errorf("color index %d out of range 0-255", n)
+		}
+		return color{index: uint8(n)}, nil
+	}
+	if s == "gray" || s == "grey" {
+		s = "bright-black"
+	}
+	for i, name := range basicNames {
+		if s == name {
+			return color{index: uint8(i)}, nil
+		}
+	}
+	return color{}, fmt.Errorf("unknown color %q", s)
+}
+
+// sgr returns the SGR parameters selecting c as foreground (or background)
+// at depth, degrading truecolor to the 256-color palette and both to the 16
+// basic colors as needed.
+func (c color) sgr(depth Depth, bg bool) string {
+	base := 38
+	if bg {
+		base = 48
+	}
+	switch {
+	case c.rgb && depth == DepthTrue:
+		return fmt.Sprintf("%d;2;%d;%d;%d", base, c.r, c.g, c.b)
+	case c.rgb && depth == Depth256:
+		return fmt.Sprintf("%d;5;%d", base, nearest256(c.r, c.g, c.b))
+	case !c.rgb && c.index >= 16 && depth >= Depth256:
+		return fmt.Sprintf("%d;5;%d", base, c.index)
+	}
+
+	index := c.index
+	if c.rgb || index >= 16 {
+		r, g, b := c.r, c.g, c.b
+		if !c.rgb {
+			r, g, b = indexRGB(index)
+		}
+		index = nearestBasic(r, g, b)
+	}
+	code := 30 + int(index)
+	if index >= 8 {
+		code = 90 + int(index) - 8
+	}
+	if bg {
+		code += 10
+	}
+	return strconv.Itoa(code)
+}
+
+// The 256-color palette: 16 basic colors, a 6x6x6 cube, then 24 grays.
+var cubeLevels = [6]uint8{0, 95, 135, 175, 215, 255}
+
+func indexRGB(i uint8) (r, g, b uint8) {
+	switch {
+	case i < 16:
+		c := basicRGB[i]
+		return c[0], c[1], c[2]
+	case i < 232:
+		i -= 16
+		return cubeLevels[i/36], cubeLevels[i/6%6], cubeLevels[i%6]
+	default:
+		v := 8 + 10*(i-232)
+		return v, v, v
+	}
+}
+
+func nearest256(r, g, b uint8) uint8 {
+	best, bestDist := uint8(16), -1
+	for i := 16; i < 256; i++ {
+		cr, cg, cb := indexRGB(uint8(i))
+		if d := distance(r, g, b, cr, cg, cb); bestDist < 0 || d < bestDist {
+			best, bestDist = uint8(i), d
+		}
+	}
+	return best
+}
+
+func nearestBasic(r, g, b uint8) uint8 {
+	best, bestDist := uint8(0), -1
+	for i, c := range basicRGB {
+		if d := distance(r, g, b, c[0], c[1], c[2]); bestDist < 0 || d < bestDist {
+			best, bestDist = uint8(i), d
+		}
+	}
+	return best
+}
+
+func distance(r1, g1, b1, r2, g2, b2 uint8) int {
+	dr, dg, db := int(r1)-int(r2), int(g1)-int(g2), int(b1)-int(b2)
+	return dr*dr + dg*dg + db*db
+}
@@ -1,45 +1,296 @@
 package palette
 
 import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/hergert/ccsl/internal/config"
 )
 
+// Escape codes of the default dark theme at 256 colors.
 const (
 	Reset  = "\x1b[0m"
 	Bold   = "\x1b[1m"
 	Dim    = "\x1b[2m"
 	Yellow = "\x1b[38;5;179m" // muted gold
 	Red    = "\x1b[38;5;167m" // muted red
+
+	// LinkEnd closes an OSC 8 hyperlink.
+	LinkEnd = "\x1b]8;;\x1b\\"
 )
 
+var attrs = map[string]int{
+	"bold":      1,
+	"dim":       2,
+	"italic":    3,
+	"underline": 4,
+	"reverse":   7,
+}
+
 type Palette struct {
-	ansi bool
+	ansi     bool
+	depth    Depth
+	colors   map[string]string // [theme.colors]
+	roles    map[string]string // role -> escape sequence, resolved up front
+	segments map[string]string // segment id -> base style
+	blocks   []string          // powerline background colors
+	links    bool              // OSC 8 hyperlinks
+	warnings []string
 }
 
+// From builds the palette for cfg's theme. Color follows config.ColorEnv,
+// so NO_COLOR, FORCE_COLOR and TERM=dumb apply even to a Config that
+// didn't come from config.Load.
 func From(cfg *config.Config) *Palette {
-	return &Palette{ansi: cfg.Theme.ANSI}
+	t := cfg.Theme
+	p := &Palette{
+		ansi:     config.ColorEnv(t.ANSI),
+		depth:    DetectDepth(os.Getenv("COLORTERM"), os.Getenv("TERM")),
+		colors:   t.Colors,
+		roles:    make(map[string]string),
+		segments: t.Segments,
+		links:    t.Hyperlinks && os.Getenv("TERM") != "dumb",
+	}
+	if d, ok := forcedDepth(os.Getenv("FORCE_COLOR")); ok {
+		p.depth = d
+	}
+	if t.Depth != "" {
+		if d, ok := parseDepth(t.Depth); ok {
+			p.depth = d
+		} else {
+			p.warnf("theme.depth: unknown depth %q (want truecolor, 256 or 16)", t.Depth)
+		}
+	}
+
+	name := t.Name
+	if name == "" {
+		name = defaultTheme
+	}
+	base, ok := themes[name]
+	if !ok {
+		p.warnf("theme.name: unknown theme %q", name)
+		base = themes[defaultTheme]
+	}
+
+	p.blocks = themeBlocks[name]
+	if p.blocks == nil {
+		p.blocks = themeBlocks[defaultTheme]
+	}
+	if len(t.Blocks) > 0 {
+		p.blocks = t.Blocks
+	}
+	for i, c := range p.blocks {
+		if _, err := p.color(c); err != nil {
+			p.warnf("theme.blocks[%d]: %v", i, err)
+			p.blocks = themeBlocks[defaultTheme]
+			break
+		}
+	}
+
+	for _, role := range roleNames {
+		spec := base[role]
+		if s, ok := t.Roles[role]; ok {
+			spec = s
+		}
+		seq, err := p.compile(spec)
+		if err != nil {
+			p.warnf("theme.roles.%s: %v", role, err)
+			seq, _ = p.compile(base[role])
+		}
+		p.roles[role] = seq
+	}
+
+	for _, role := range sortedKeys(t.Roles) {
+		if _, ok := p.roles[role]; !ok {
+			p.warnf("theme.roles.%s: unknown role (want one of %s)", role, strings.Join(roleNames, ", "))
+		}
+	}
+	for _, id := range sortedKeys(t.Segments) {
+		if _, err := p.compile(t.Segments[id]); err != nil {
+			p.warnf("theme.segments.%s: %v", id, err)
+		}
+	}
+	return p
+}
+
+// Warnings lists theme settings that were ignored, for `ccsl doctor`.
+func (p *Palette) Warnings() []string {
+	if p == nil {
+		return nil
+	}
+	return p.warnings
+}
+
+func (p *Palette) warnf(format string, args ...any) {
+	p.warnings = append(p.warnings, fmt.Sprintf(format, args...))
 }
 
+// Apply wraps text in the escape codes for style: a role ("warn", "dim",
+// ...), a style spec like "bold #ff8800 on:236", or a raw escape sequence.
+// A nil Palette renders plain text.
 func (p *Palette) Apply(text, style string) string {
-	if !p.ansi || style == "" || style == "normal" {
+	if p == nil || !p.ansi {
 		return text
 	}
+	seq := p.sequence(style)
+	if seq == "" {
+		return text
+	}
+	return seq + text + Reset
+}
 
-	switch style {
-	case "bold":
-		return Bold + text + Reset
-	case "dim":
-		return Dim + text + Reset
-	case "yellow", "warn":
-		return Yellow + text + Reset
-	case "red", "error":
-		return Red + text + Reset
-	default:
-		if strings.HasPrefix(style, "\x1b[") {
-			return style + text + Reset
+// Link makes text an OSC 8 hyperlink to url when [theme] hyperlinks is on.
+// A URL with control characters, which could end the sequence early, is
+// left out.
+func (p *Palette) Link(text, url string) string {
+	if p == nil || !p.links || url == "" || text == "" {
+		return text
+	}
+	for _, r := range url {
+		if r < 0x20 || r == 0x7f {
+			return text
 		}
+	}
+	return "\x1b]8;;" + url + "\x1b\\" + text + LinkEnd
+}
+
+// Colored reports whether Apply emits escape codes at all.
+func (p *Palette) Colored() bool {
+	return p != nil && p.ansi
+}
+
+// BlockColor is the background of the i-th powerline block, cycling
+// through the theme's blocks.
+func (p *Palette) BlockColor(i int) string {
+	if p == nil || len(p.blocks) == 0 {
+		return ""
+	}
+	return p.blocks[i%len(p.blocks)]
+}
+
+// Block renders text in style on background color bg. Resets inside text
+// (from segments that color parts of themselves) re-enter the block, so
+// the background runs to the end.
+func (p *Palette) Block(text, style, bg string) string {
+	if p == nil || !p.ansi {
 		return text
 	}
+	bgSeq, _ := p.compile("on:" + bg)
+	seq := bgSeq + p.sequence(style)
+	return seq + strings.ReplaceAll(text, Reset, Reset+seq) + Reset
+}
+
+// Separator draws glyph in color from on background to; an empty to is the
+// terminal's own background.
+func (p *Palette) Separator(glyph, from, to string) string {
+	if p == nil || !p.ansi {
+		return glyph
+	}
+	spec := from
+	if to != "" {
+		spec += " on:" + to
+	}
+	seq, _ := p.compile(spec)
+	return seq + glyph + Reset
+}
+
+func (p *Palette) sequence(style string) string {
+	switch {
+	case style == "" || style == "normal":
+		return ""
+	case strings.HasPrefix(style, "\x1b["):
+		return style
+	}
+	if seq, ok := p.roles[roleAliases[style]]; ok {
+		return seq
+	}
+	if seq, ok := p.roles[style]; ok {
+		return seq
+	}
+	seq, _ := p.compile(style)
+	return seq
+}
+
+// SegmentStyle is the style to render segment id with: the theme's base
+// style for it, unless the segment is signalling a severity.
+func (p *Palette) SegmentStyle(id, style string) string {
+	if p == nil {
+		return style
+	}
+	switch style {
+	case "warn", "yellow", "error", "red", "ok":
+		return style
+	}
+	if s, ok := p.segments[id]; ok {
+		return s
+	}
+	return style
+}
+
+// compile turns a style spec into one escape sequence. Words are attributes
+// (bold, dim, italic, underline, reverse), roles already resolved, colors,
+// or "on:<color>" for the background; a color is a [theme.colors] name,
+// "#rrggbb", an index 0-255 or a basic color name.
+func (p *Palette) compile(spec string) (string, error) {
+	var params []string
+	for _, word := range strings.Fields(spec) {
+		if n, ok := attrs[word]; ok {
+			params = append(params, strconv.Itoa(n))
+			continue
+		}
+		if bg, ok := strings.CutPrefix(word, "on:"); ok {
+			c, err := p.color(bg)
+			if err != nil {
+				return "", err
+			}
+			params = append(params, c.sgr(p.depth, true))
+			continue
+		}
+		if seq, ok := p.roles[word]; ok {
+			params = append(params, sgrParams(seq)...)
+			continue
+		}
+		c, err := p.color(word)
+		if err != nil {
+			return "", err
+		}
+		params = append(params, c.sgr(p.depth, false))
+	}
+	if len(params) == 0 {
+		return "", nil
+	}
+	return "\x1b[" + strings.Join(params, ";") + "m", nil
+}
+
+func (p *Palette) color(name string) (color, error) {
+	if v, ok := p.colors[name]; ok {
+		c, err := parseColor(v)
+		if err != nil {
+			return color{}, fmt.Errorf("theme.colors.%s: %v", name, err)
+		}
+		return c, nil
+	}
+	return parseColor(name)
+}
+
+// sgrParams splits "\x1b[1;38;5;179m" back into its parameters so roles can
+// be combined inside a larger spec.
+func sgrParams(seq string) []string {
+	inner := strings.TrimSuffix(strings.TrimPrefix(seq, "\x1b["), "m")
+	if inner == "" {
+		return nil
+	}
+	return []string{inner}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
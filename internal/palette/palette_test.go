@@ -0,0 +1,190 @@
+package palette
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hergert/ccsl/internal/config"
+)
+
+func themed(t config.ThemeConfig) *Palette {
+	t.ANSI = true
+	return From(&config.Config{Theme: t})
+}
+
+func TestDetectDepth(t *testing.T) {
+	cases := []struct {
+		colorterm, term string
+		want            Depth
+	}{
+		{"truecolor", "xterm-256color", DepthTrue},
+		{"24bit", "xterm", DepthTrue},
+		{"", "xterm-256color", Depth256},
+		{"", "", Depth256},
+		{"", "xterm", Depth16},
+		{"", "linux", Depth16},
+	}
+	for _, tc := range cases {
+		if got := DetectDepth(tc.colorterm, tc.term); got != tc.want {
+			t.Errorf("DetectDepth(%q, %q) = %v, want %v", tc.colorterm, tc.term, got, tc.want)
+		}
+	}
+}
+
+func TestDefaultThemeMatchesOriginalCodes(t *testing.T) {
+	p := themed(config.ThemeConfig{Depth: "256"})
+	cases := map[string]string{
+		"bold":   Bold,
+		"dim":    Dim,
+		"yellow": Yellow,
+		"warn":   Yellow,
+		"red":    Red,
+		"error":  Red,
+	}
+	for style, code := range cases {
+		if got := p.Apply("x", style); got != code+"x"+Reset {
+			t.Errorf("Apply(%q) = %q, want %q", style, got, code+"x"+Reset)
+		}
+	}
+	if got := p.Apply("x", "normal"); got != "x" {
+		t.Errorf("normal = %q", got)
+	}
+}
+
+func TestColorDegradation(t *testing.T) {
+	cases := []struct {
+		depth string
+		style string
+		want  string
+	}{
+		{"truecolor", "#ff8800", "\x1b[38;2;255;136;0m"},
+		{"256", "#ff8800", "\x1b[38;5;208m"},
+		{"16", "#ff8800", "\x1b[33m"},
+		{"truecolor", "179", "\x1b[38;5;179m"},
+		{"16", "179", "\x1b[33m"},
+		{"16", "bright-blue", "\x1b[94m"},
+		{"256", "bold #fff on:236", "\x1b[1;38;5;231;48;5;236m"},
+		{"16", "on:blue", "\x1b[44m"},
+	}
+	for _, tc := range cases {
+		p := themed(config.ThemeConfig{Depth: tc.depth})
+		if got := p.Apply("x", tc.style); got != tc.want+"x"+Reset {
+			t.Errorf("%s %q = %q, want %q", tc.depth, tc.style, got, tc.want+"x"+Reset)
+		}
+	}
+}
+
+func TestBuiltinThemes(t *testing.T) {
+	for _, name := range []string{"dark", "light", "solarized", "high-contrast"} {
+		p := themed(config.ThemeConfig{Name: name, Depth: "truecolor"})
+		if w := p.Warnings(); len(w) > 0 {
+			t.Errorf("%s: %v", name, w)
+		}
+		for _, role := range []string{"warn", "error", "ok", "accent"} {
+			if got := p.Apply("x", role); got == "x" {
+				t.Errorf("%s: role %s has no color", name, role)
+			}
+		}
+	}
+	if got := themed(config.ThemeConfig{Name: "solarized", Depth: "truecolor"}).Apply("x", "error"); got != "\x1b[38;2;220;50;47mx"+Reset {
+		t.Errorf("solarized error = %q", got)
+	}
+	if got := themed(config.ThemeConfig{Name: "high-contrast"}).Apply("x", "dim"); got != "x" {
+		t.Errorf("high-contrast dim = %q, want plain", got)
+	}
+}
+
+func TestThemeOverrides(t *testing.T) {
+	p := themed(config.ThemeConfig{
+		Depth:    "256",
+		Colors:   map[string]string{"gold": "#d7af5f"},
+		Roles:    map[string]string{"warn": "gold bold", "accent": "on:gold"},
+		Segments: map[string]string{"model": "accent", "git": "warn"},
+	})
+	if got := p.Apply("x", "warn"); got != "\x1b[38;5;179;1mx"+Reset {
+		t.Errorf("warn = %q", got)
+	}
+	if got := p.SegmentStyle("model", "bold"); got != "accent" {
+		t.Errorf("model base style = %q, want accent", got)
+	}
+	if got := p.SegmentStyle("model", "red"); got != "red" {
+		t.Errorf("severity should win over base style, got %q", got)
+	}
+	if got := p.SegmentStyle("cwd", "dim"); got != "dim" {
+		t.Errorf("unthemed segment = %q, want its own style", got)
+	}
+}
+
+func TestThemeWarnings(t *testing.T) {
+	p := themed(config.ThemeConfig{
+		Name:     "neon",
+		Depth:    "65k",
+		Colors:   map[string]string{"bad": "#12"},
+		Roles:    map[string]string{"warn": "bad", "loud": "red"},
+		Segments: map[string]string{"git": "chartreuse"},
+	})
+	want := []string{"theme.depth", "theme.name", "theme.roles.warn", "theme.roles.loud", "theme.segments.git"}
+	got := p.Warnings()
+	if len(got) != len(want) {
+		t.Fatalf("Warnings = %q", got)
+	}
+	for i, w := range want {
+		if !strings.HasPrefix(got[i], w) {
+			t.Errorf("warning %d = %q, want prefix %q", i, got[i], w)
+		}
+	}
+	// A broken role falls back to the theme's own.
+	if got := p.Apply("x", "warn"); !strings.Contains(got, "\x1b[") {
+		t.Errorf("warn fallback = %q", got)
+	}
+}
+
+func TestANSIOff(t *testing.T) {
+	p := From(&config.Config{Theme: config.ThemeConfig{Name: "solarized"}})
+	if got := p.Apply("x", "warn"); got != "x" {
+		t.Errorf("Apply with ansi off = %q", got)
+	}
+}
+
+func TestColorEnvPolicy(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	if got := themed(config.ThemeConfig{}).Apply("x", "warn"); got != "x" {
+		t.Errorf("NO_COLOR: Apply = %q, want plain", got)
+	}
+	t.Setenv("FORCE_COLOR", "3")
+	if got := themed(config.ThemeConfig{Name: "solarized"}).Apply("x", "warn"); got != "\x1b[38;2;181;137;0mx"+Reset {
+		t.Errorf("FORCE_COLOR=3: Apply = %q, want truecolor", got)
+	}
+	var nilPal *Palette
+	if got := nilPal.Apply("x", "warn"); got != "x" {
+		t.Errorf("nil palette: Apply = %q", got)
+	}
+	if got := nilPal.SegmentStyle("model", "bold"); got != "bold" {
+		t.Errorf("nil palette: SegmentStyle = %q", got)
+	}
+	if got := nilPal.Warnings(); got != nil {
+		t.Errorf("nil palette: Warnings = %q", got)
+	}
+}
+
+func TestLink(t *testing.T) {
+	t.Setenv("TERM", "xterm-256color")
+	on := From(&config.Config{Theme: config.ThemeConfig{Hyperlinks: true}})
+	if got, want := on.Link("main", "https://x.test/a"), "\x1b]8;;https://x.test/a\x1b\\main"+LinkEnd; got != want {
+		t.Errorf("Link = %q, want %q", got, want)
+	}
+	if got := on.Link("main", "https://x.test/\x1b]0;pwned\a"); got != "main" {
+		t.Errorf("Link with control characters = %q", got)
+	}
+	if got := themed(config.ThemeConfig{}).Link("main", "https://x.test/a"); got != "main" {
+		t.Errorf("Link without hyperlinks = %q", got)
+	}
+	t.Setenv("TERM", "dumb")
+	if got := From(&config.Config{Theme: config.ThemeConfig{Hyperlinks: true}}).Link("main", "https://x.test/a"); got != "main" {
+		t.Errorf("Link on TERM=dumb = %q", got)
+	}
+	var nilPal *Palette
+	if got := nilPal.Link("main", "https://x.test/a"); got != "main" {
+		t.Errorf("nil Link = %q", got)
+	}
+}
@@ -0,0 +1,60 @@
+package palette
+
+// A theme maps roles to styles. Segments ask for roles ("warn", "dim", ...)
+// rather than colors, so one theme switch recolors every segment.
+type theme map[string]string
+
+// Roles every theme defines.
+var roleNames = []string{"bold", "dim", "warn", "error", "ok", "accent"}
+
+// Segments and templates written before themes existed say "yellow" and
+// "red"; as a whole style those still mean the warn and error roles.
+var roleAliases = map[string]string{"yellow": "warn", "red": "error"}
+
+var themes = map[string]theme{
+	// The original muted 256-color look.
+	"dark": {
+		"bold":   "bold",
+		"dim":    "dim",
+		"warn":   "179",
+		"error":  "167",
+		"ok":     "108",
+		"accent": "110",
+	},
+	// Darker hues that stay readable on a white background.
+	"light": {
+		"bold":   "bold",
+		"dim":    "dim",
+		"warn":   "130",
+		"error":  "124",
+		"ok":     "28",
+		"accent": "25",
+	},
+	"solarized": {
+		"bold":   "bold",
+		"dim":    "#586e75",
+		"warn":   "#b58900",
+		"error":  "#dc322f",
+		"ok":     "#859900",
+		"accent": "#268bd2",
+	},
+	// Basic colors only, no dimming: legible on any terminal and palette.
+	"high-contrast": {
+		"bold":   "bold",
+		"dim":    "",
+		"warn":   "bright-yellow bold",
+		"error":  "bright-red bold",
+		"ok":     "bright-green",
+		"accent": "bright-cyan bold",
+	},
+}
+
+const defaultTheme = "dark"
+
+// Background colors powerline mode cycles through, per theme.
+var themeBlocks = map[string][]string{
+	"dark":          {"237", "239"},
+	"light":         {"254", "251"},
+	"solarized":     {"#073642", "#002b36"},
+	"high-contrast": {"blue", "black"},
+}
@@ -0,0 +1,96 @@
+// Package pricing estimates what a session cost from its token counts, for
+// setups (gateways, Bedrock, Vertex) where Claude Code reports no cost.
+package pricing
+
+import (
+	_ "embed"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/hergert/ccsl/internal/config"
+	"github.com/hergert/ccsl/internal/transcript"
+)
+
+//go:embed prices.toml
+var builtinPrices string
+
+// Rates is what a model costs in USD per million tokens.
+type Rates struct {
+	Input      float64 `toml:"input"`
+	Output     float64 `toml:"output"`
+	CacheRead  float64 `toml:"cache_read"`
+	CacheWrite float64 `toml:"cache_write"`
+}
+
+// Table maps model id fragments to their rates.
+type Table map[string]Rates
+
+// Load returns the built-in table with the [pricing] overrides on top. An
+// override of a listed model keeps the listed rates it leaves unset; one of
+// a new model prices unset cache reads at 0.1x and writes at 1.25x input.
+func Load(overrides map[string]config.Price) Table {
+	t := make(Table)
+	_, _ = toml.Decode(builtinPrices, &t)
+	for key, p := range overrides {
+		r, listed := t[key]
+		set(&r.Input, p.Input)
+		set(&r.Output, p.Output)
+		if !listed {
+			r.CacheRead, r.CacheWrite = r.Input*0.1, r.Input*1.25
+		}
+		set(&r.CacheRead, p.CacheRead)
+		set(&r.CacheWrite, p.CacheWrite)
+		t[key] = r
+	}
+	return t
+}
+
+func set(dst *float64, v *float64) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+// Lookup finds the rates for a model id: those of the longest key the id
+// contains, so "claude-opus-4-1-20250805" is priced as "claude-opus-4-1"
+// rather than "claude-opus". Ids and keys are compared normalized.
+func (t Table) Lookup(model string) (Rates, bool) {
+	model = normalize(model)
+	var best string
+	for key := range t {
+		if len(key) > len(best) && strings.Contains(model, normalize(key)) {
+			best = key
+		}
+	}
+	if best == "" {
+		return Rates{}, false
+	}
+	return t[best], true
+}
+
+// normalize spells a model id the way Anthropic's API does: Vertex puts
+// an "@" where the others have "-" before the date.
+func normalize(id string) string {
+	return strings.ReplaceAll(strings.ToLower(id), "@", "-")
+}
+
+// Cost prices usage at r.
+func Cost(r Rates, u transcript.Usage) float64 {
+	return (float64(u.Input)*r.Input +
+		float64(u.Output)*r.Output +
+		float64(u.CacheRead)*r.CacheRead +
+		float64(u.CacheWrite)*r.CacheWrite) / 1e6
+}
+
+// Estimate prices the usage of each model; ok is false when none of them
+// is in the table. Models missing from it are left out of the sum.
+func (t Table) Estimate(byModel map[string]transcript.Usage) (usd float64, ok bool) {
+	for model, u := range byModel {
+		if r, found := t.Lookup(model); found {
+			usd += Cost(r, u)
+			ok = true
+		}
+	}
+	return usd, ok
+}
@@ -0,0 +1,92 @@
+package pricing
+
+import (
+	"math"
+	"testing"
+
+	"github.com/hergert/ccsl/internal/config"
+	"github.com/hergert/ccsl/internal/transcript"
+)
+
+func TestLookup(t *testing.T) {
+	table := Load(nil)
+	cases := []struct {
+		model string
+		input float64
+	}{
+		{"claude-opus-4-8", 5},
+		{"claude-opus-4-1-20250805", 15},
+		{"claude-opus-4-20250514", 15},
+		{"claude-opus-4-0", 15},
+		{"claude-opus-4-1", 15},
+		{"claude-sonnet-4-0", 3},
+		{"claude-sonnet-4-5", 3},
+		{"claude-haiku-4-5", 1},
+		{"claude-3-7-sonnet-latest", 3},
+		{"claude-3-5-haiku-latest", 0.8},
+		{"us.anthropic.claude-opus-4-20250514-v1:0", 15},
+		{"us.anthropic.claude-sonnet-4-5-20250929-v1:0", 3},
+		{"claude-haiku-4-5@20251001", 1},
+		{"claude-3-5-haiku-20241022", 0.8},
+		{"claude-opus-4@20250514", 15},
+		{"claude-opus-4-1@20250805", 15},
+		{"claude-sonnet-4-5@20250929", 3},
+		{"claude-3-opus-20240229", 15},
+		{"anthropic.claude-3-opus-20240229-v1:0", 15},
+		{"claude-3-5-sonnet-20241022", 3},
+		{"claude-3-5-sonnet-v2@20241022", 3},
+		{"claude-3-haiku@20240307", 0.25},
+	}
+	for _, tc := range cases {
+		p, ok := table.Lookup(tc.model)
+		if !ok || p.Input != tc.input {
+			t.Errorf("Lookup(%q) = %+v, %v; want input %g", tc.model, p, ok, tc.input)
+		}
+	}
+	if _, ok := table.Lookup("gpt-5"); ok {
+		t.Error("Lookup priced an unknown model")
+	}
+}
+
+func usd(v float64) *float64 { return &v }
+
+func TestOverrides(t *testing.T) {
+	table := Load(map[string]config.Price{
+		"claude-sonnet":   {Input: usd(2.4)},
+		"claude-haiku":    {CacheRead: usd(0)},
+		"my-gateway-fast": {Input: usd(2), Output: usd(10)},
+		"local-model":     {Input: usd(0), Output: usd(0)},
+	})
+	cases := []struct {
+		model string
+		want  Rates
+	}{
+		{"claude-sonnet-4-5", Rates{Input: 2.4, Output: 15, CacheRead: 0.3, CacheWrite: 3.75}},
+		{"claude-haiku-4-5", Rates{Input: 1, Output: 5, CacheRead: 0, CacheWrite: 1.25}},
+		{"my-gateway-fast-v2", Rates{Input: 2, Output: 10, CacheRead: 0.2, CacheWrite: 2.5}},
+		{"local-model-7b", Rates{}},
+	}
+	for _, tc := range cases {
+		if got, ok := table.Lookup(tc.model); !ok || got != tc.want {
+			t.Errorf("Lookup(%q) = %+v, %v; want %+v", tc.model, got, ok, tc.want)
+		}
+	}
+	if cost, ok := table.Estimate(map[string]transcript.Usage{"local-model-7b": {Input: 1_000_000}}); !ok || cost != 0 {
+		t.Errorf("Estimate of a free model = %g, %v; want 0, true", cost, ok)
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	usd, ok := Load(nil).Estimate(map[string]transcript.Usage{
+		"claude-opus-4-8":   {Input: 1000, Output: 10_000, CacheRead: 1_000_000, CacheWrite: 40_000},
+		"claude-sonnet-4-5": {Input: 1_000_000},
+		"unknown":           {Input: 1_000_000},
+	})
+	// opus: 0.005 + 0.25 + 0.5 + 0.25; sonnet: 3
+	if want := 4.005; !ok || math.Abs(usd-want) > 1e-9 {
+		t.Errorf("Estimate = %g, %v; want %g", usd, ok, want)
+	}
+	if _, ok := Load(nil).Estimate(map[string]transcript.Usage{"unknown": {Input: 1}}); ok {
+		t.Error("Estimate of unpriced models is ok")
+	}
+}
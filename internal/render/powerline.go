@@ -0,0 +1,115 @@
+package render
+
+import (
+	"os"
+	"strings"
+
+	"github.com/hergert/ccsl/internal/config"
+	"github.com/hergert/ccsl/internal/palette"
+	"github.com/hergert/ccsl/internal/types"
+)
+
+const (
+	powerlineGlyph = "\ue0b0"
+	asciiGlyph     = ">"
+)
+
+// Status renders every configured line in the configured mode.
+func Status(ui config.UIConfig, segments []types.Segment, pal *palette.Palette, maxLen int) string {
+	if ui.Mode != "powerline" {
+		return Lines(ui.Templates(), segments, pal, maxLen)
+	}
+	glyph := separatorGlyph(ui, os.Getenv("TERM"))
+	var out []string
+	for _, tmpl := range ui.Templates() {
+		t, _ := Parse(tmpl)
+		if line := t.Powerline(segments, pal, maxLen, glyph); line != "" {
+			out = append(out, line)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// The Linux console has no powerline glyphs.
+func separatorGlyph(ui config.UIConfig, term string) string {
+	switch {
+	case ui.ASCII || term == "linux":
+		return asciiGlyph
+	case ui.Separator != "":
+		return ui.Separator
+	}
+	return powerlineGlyph
+}
+
+type block struct {
+	text  string
+	style string
+	link  string
+}
+
+// Powerline lays out the template's non-empty segments, in order, as
+// padded blocks on alternating backgrounds joined by glyph. Literal text,
+// groups and prefix/suffix are ignored; style= still applies. A line that
+// is too long degrades like a template line, except that the last resort
+// shortens the last block rather than cutting through the glyphs.
+func (t *Template) Powerline(segments []types.Segment, pal *palette.Palette, maxLen int, glyph string) string {
+	segMap := t.segments(segments)
+
+	var nodes []node
+	var walk func([]node)
+	walk = func(ns []node) {
+		for _, n := range ns {
+			walk(n.group)
+			if n.id != "" {
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	walk(t.nodes)
+
+	blocks := func(f forms) []block {
+		var bs []block
+		for _, n := range nodes {
+			seg, ok := segMap[n.id]
+			if !ok || seg.Text == "" || f[n.id].dropped {
+				continue
+			}
+			style := pal.SegmentStyle(n.id, seg.Style)
+			if n.style != "" {
+				style = n.style
+			}
+			bs = append(bs, block{text: f[n.id].text(seg), style: style, link: seg.Link})
+		}
+		return bs
+	}
+	draw := func(bs []block) string {
+		var out strings.Builder
+		for i, b := range bs {
+			bg := pal.BlockColor(i)
+			if i > 0 {
+				out.WriteString(pal.Separator(glyph, pal.BlockColor(i-1), bg))
+			}
+			out.WriteString(pal.Block(" "+pal.Link(b.text, b.link)+" ", b.style, bg))
+		}
+		if pal.Colored() && len(bs) > 0 {
+			out.WriteString(pal.Separator(glyph, pal.BlockColor(len(bs)-1), ""))
+		}
+		return out.String()
+	}
+
+	out, f := fit(segMap, t.IDs(), maxLen, func(f forms) string { return draw(blocks(f)) })
+	if over := visibleLen(out) - maxLen; maxLen > 0 && over > 0 {
+		bs := blocks(f)
+		if len(bs) == 0 {
+			return out
+		}
+		b := &bs[len(bs)-1]
+		keep := visibleLen(b.text) - over - 3
+		if keep < 0 {
+			keep = 0
+		}
+		b.text = truncateWidth(b.text, keep) + "..."
+		out = draw(bs)
+	}
+	return out
+}
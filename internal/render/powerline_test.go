@@ -0,0 +1,102 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hergert/ccsl/internal/config"
+	"github.com/hergert/ccsl/internal/palette"
+	"github.com/hergert/ccsl/internal/types"
+)
+
+func powerlineSegs() []types.Segment {
+	return []types.Segment{
+		{ID: "model", Text: "Opus", Style: "bold", Priority: 90},
+		{ID: "cwd", Text: "~/src", Style: "dim", Priority: 70},
+		{ID: "git", Text: "main", Style: "dim", Priority: 60},
+	}
+}
+
+func TestPowerlinePlain(t *testing.T) {
+	tpl, _ := Parse("{model} :: {missing}{cwd?prefix=in }{[ {git}]}")
+	got := tpl.Powerline(powerlineSegs(), plainPalette(), 0, ">")
+	if want := " Opus > ~/src > main "; got != want {
+		t.Errorf("Powerline = %q, want %q", got, want)
+	}
+}
+
+func TestPowerlineColors(t *testing.T) {
+	pal := palette.From(&config.Config{Theme: config.ThemeConfig{
+		ANSI: true, Depth: "256", Blocks: []string{"1", "2"},
+	}})
+	tpl, _ := Parse("{model}{cwd}")
+	got := tpl.Powerline(powerlineSegs(), pal, 0, ">")
+	want := "\x1b[41m\x1b[1m Opus " + palette.Reset +
+		"\x1b[31;42m>" + palette.Reset +
+		"\x1b[42m\x1b[2m ~/src " + palette.Reset +
+		"\x1b[32m>" + palette.Reset
+	if got != want {
+		t.Errorf("Powerline =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestPowerlineKeepsBackgroundAfterInnerReset(t *testing.T) {
+	pal := palette.From(&config.Config{Theme: config.ThemeConfig{
+		ANSI: true, Depth: "256", Blocks: []string{"1"},
+	}})
+	segs := []types.Segment{{ID: "git", Text: "main" + palette.Red + "⇣1" + palette.Reset, Priority: 60}}
+	tpl, _ := Parse("{git}")
+	got := tpl.Powerline(segs, pal, 0, ">")
+	if !strings.Contains(got, palette.Reset+"\x1b[41m ") {
+		t.Errorf("background not restored after inner reset: %q", got)
+	}
+}
+
+func TestPowerlineTruncation(t *testing.T) {
+	tpl, _ := Parse("{model}{cwd}{git}")
+	// Each block is its text + 2 padding; plain mode has no closing glyph.
+	got := tpl.Powerline(powerlineSegs(), plainPalette(), 14, ">")
+	if want := " Opus > ~/src "; got != want {
+		t.Errorf("Powerline = %q, want %q", got, want)
+	}
+	got = tpl.Powerline(powerlineSegs(), plainPalette(), 5, ">")
+	if want := " ... "; got != want {
+		t.Errorf("Powerline = %q, want %q", got, want)
+	}
+}
+
+func TestPowerlineTruncationPolicies(t *testing.T) {
+	segs := powerlineSegs()
+	segs[0].Truncate = "never"
+	segs[1].Short, segs[1].Truncate = "~", "shorten"
+	tpl, _ := Parse("{model}{cwd}{git}")
+	got := tpl.Powerline(segs, plainPalette(), 12, ">")
+	if want := " Opus > ~ "; got != want {
+		t.Errorf("Powerline = %q, want %q", got, want)
+	}
+}
+
+func TestSeparatorGlyph(t *testing.T) {
+	cases := []struct {
+		ui   config.UIConfig
+		term string
+		want string
+	}{
+		{config.UIConfig{}, "xterm-256color", ""},
+		{config.UIConfig{Separator: "▶"}, "xterm-256color", "▶"},
+		{config.UIConfig{Separator: "▶", ASCII: true}, "xterm-256color", ">"},
+		{config.UIConfig{}, "linux", ">"},
+	}
+	for _, tc := range cases {
+		if got := separatorGlyph(tc.ui, tc.term); got != tc.want {
+			t.Errorf("separatorGlyph(%+v, %q) = %q, want %q", tc.ui, tc.term, got, tc.want)
+		}
+	}
+}
+
+func TestStatusPowerlineMode(t *testing.T) {
+	ui := config.UIConfig{Lines: []string{"{model}", "{nothing}"}, Mode: "powerline", ASCII: true}
+	if got := Status(ui, powerlineSegs(), plainPalette(), 0); got != " Opus " {
+		t.Errorf("Status = %q", got)
+	}
+}
@@ -1,29 +1,13 @@
 package render
 
 import (
-	"net/url"
-	"regexp"
 	"strconv"
 	"strings"
-	"unicode/utf8"
 
 	"github.com/hergert/ccsl/internal/palette"
 	"github.com/hergert/ccsl/internal/types"
 )
 
-var templateRe = regexp.MustCompile(`\{([-\w:.]+)(\?[^}]*)?\}`)
-var ansiRe = regexp.MustCompile(`\x1b\[[0-9;]*m`)
-
-type segmentPos struct {
-	start    int
-	end      int
-	priority int
-}
-
-func visibleLen(s string) int {
-	return utf8.RuneCountInString(ansiRe.ReplaceAllString(s, ""))
-}
-
 // EffectiveMaxLen caps the configured truncate width at the terminal width
 // Claude Code reports via the COLUMNS env var (set since 2.1.153).
 func EffectiveMaxLen(configured int, columnsEnv string) int {
@@ -37,109 +21,84 @@ func EffectiveMaxLen(configured int, columnsEnv string) int {
 	return configured
 }
 
-func Line(template string, segments []types.Segment, pal *palette.Palette, maxLen int) string {
-	segMap := make(map[string]types.Segment)
-	for _, seg := range segments {
-		segMap[seg.ID] = seg
-	}
-
-	var positions []segmentPos
-	var result strings.Builder
-	lastEnd := 0
-
-	for _, match := range templateRe.FindAllStringSubmatchIndex(template, -1) {
-		result.WriteString(template[lastEnd:match[0]])
-
-		key := template[match[2]:match[3]]
-		var query string
-		if match[4] >= 0 && match[5] >= 0 {
-			query = template[match[4]:match[5]]
+// Lines renders one status line per template, each truncated on its own
+// against maxLen. Lines whose segments all came up empty are dropped.
+func Lines(templates []string, segments []types.Segment, pal *palette.Palette, maxLen int) string {
+	var out []string
+	for _, tmpl := range templates {
+		if line := Line(tmpl, segments, pal, maxLen); strings.TrimSpace(line) != "" {
+			out = append(out, line)
 		}
-
-		seg, ok := segMap[key]
-		if ok && seg.Text != "" {
-			var prefix, suffix string
-			if query != "" {
-				q := strings.TrimPrefix(query, "?")
-				vals, _ := url.ParseQuery(q)
-				prefix = vals.Get("prefix")
-				suffix = vals.Get("suffix")
-			}
-
-			start := result.Len()
-			result.WriteString(prefix)
-			result.WriteString(pal.Apply(seg.Text, seg.Style))
-			result.WriteString(suffix)
-
-			positions = append(positions, segmentPos{
-				start:    start,
-				end:      result.Len(),
-				priority: seg.Priority,
-			})
-		}
-
-		lastEnd = match[1]
 	}
-	result.WriteString(template[lastEnd:])
+	return strings.Join(out, "\n")
+}
+
+func Line(template string, segments []types.Segment, pal *palette.Palette, maxLen int) string {
+	t, _ := Parse(template)
+	return t.Line(segments, pal, maxLen)
+}
 
-	out := result.String()
+// Line renders the template against segments, fitted to maxLen visible
+// columns (0 for no limit): segments are dropped, shortened and ellipsized
+// as their policies allow, and only then is the line itself cut.
+func (t *Template) Line(segments []types.Segment, pal *palette.Palette, maxLen int) string {
+	segMap := t.segments(segments)
+
+	out, _ := fit(segMap, t.IDs(), maxLen, func(f forms) string {
+		r := &lineRenderer{segs: segMap, pal: pal, forms: f}
+		r.render(t.nodes)
+		return string(r.buf)
+	})
 	if maxLen > 0 && visibleLen(out) > maxLen {
-		out = truncateWithPositions(out, positions, maxLen)
+		out = ellipsize(out, maxLen)
 	}
 
 	return out
 }
 
-func truncateWithPositions(text string, positions []segmentPos, maxLen int) string {
-	if maxLen <= 3 {
-		return "..."[:maxLen]
-	}
-
-	if len(positions) == 0 {
-		return runesTruncate(text, maxLen-3) + "..."
-	}
-
-	low := positions[0]
-	for _, p := range positions[1:] {
-		if p.priority < low.priority {
-			low = p
-		}
-	}
-
-	segText := text[low.start:low.end]
-	budget := maxLen - (visibleLen(text) - visibleLen(segText))
-	if budget > 3 {
-		trimmed := runesTruncate(segText, budget-3) + "..."
-		out := text[:low.start] + trimmed + text[low.end:]
-		if visibleLen(out) <= maxLen {
-			return out
-		}
-	}
-
-	return runesTruncate(text, maxLen-3) + "..."
+type lineRenderer struct {
+	segs  map[string]types.Segment
+	pal   *palette.Palette
+	forms forms
+	buf   []byte
 }
 
-func runesTruncate(s string, n int) string {
-	if n <= 0 {
-		return ""
-	}
-
-	var result strings.Builder
-	visible := 0
-	i := 0
-
-	for i < len(s) && visible < n {
-		if loc := ansiRe.FindStringIndex(s[i:]); loc != nil && loc[0] == 0 {
-			result.WriteString(s[i : i+loc[1]])
-			i += loc[1]
-			continue
+// render writes nodes and reports whether any segment among them had
+// content. A group without one is rolled back, literal text included.
+func (r *lineRenderer) render(nodes []node) bool {
+	shown := false
+	for _, n := range nodes {
+		switch {
+		case n.group != nil:
+			mark := len(r.buf)
+			if r.render(n.group) {
+				shown = true
+			} else {
+				r.buf = r.buf[:mark]
+			}
+		case n.id != "":
+			seg, ok := r.segs[n.id]
+			f := r.forms[n.id]
+			if f.dropped {
+				continue
+			}
+			if !ok || seg.Text == "" {
+				if n.orElse != "" {
+					r.buf = append(r.buf, r.pal.Apply(n.orElse, n.style)...)
+				}
+				continue
+			}
+			style := r.pal.SegmentStyle(n.id, seg.Style)
+			if n.style != "" {
+				style = n.style
+			}
+			r.buf = append(r.buf, n.prefix...)
+			r.buf = append(r.buf, r.pal.Link(r.pal.Apply(f.text(seg), style), seg.Link)...)
+			r.buf = append(r.buf, n.suffix...)
+			shown = true
+		default:
+			r.buf = append(r.buf, n.text...)
 		}
-
-		r, size := utf8.DecodeRuneInString(s[i:])
-		result.WriteRune(r)
-		i += size
-		visible++
 	}
-
-	return result.String()
+	return shown
 }
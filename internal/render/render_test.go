@@ -14,7 +14,7 @@ func plainPalette() *palette.Palette {
 }
 
 func ansiPalette() *palette.Palette {
-	return palette.From(&config.Config{Theme: config.ThemeConfig{ANSI: true}})
+	return palette.From(&config.Config{Theme: config.ThemeConfig{ANSI: true, Depth: "256"}})
 }
 
 func TestPrefixOnlyWithContent(t *testing.T) {
@@ -34,8 +34,8 @@ func TestMissingSegmentLeavesNoResidue(t *testing.T) {
 
 func TestTruncateTrimsLowestPriority(t *testing.T) {
 	segs := []types.Segment{
-		{ID: "low", Text: "LLLLLLLLLL", Priority: 10},
-		{ID: "high", Text: "HHHHHHHHHH", Priority: 90},
+		{ID: "low", Text: "LLLLLLLLLL", Priority: 10, Truncate: "shorten"},
+		{ID: "high", Text: "HHHHHHHHHH", Priority: 90, Truncate: "never"},
 	}
 	got := Line("{low} {high}", segs, plainPalette(), 15)
 	want := "L... HHHHHHHHHH"
@@ -44,6 +44,52 @@ func TestTruncateTrimsLowestPriority(t *testing.T) {
 	}
 }
 
+func TestTruncateLadder(t *testing.T) {
+	segs := func(lowPolicy string) []types.Segment {
+		return []types.Segment{
+			{ID: "low", Text: "LLLLLLLLLL", Short: "LL", Priority: 10, Truncate: lowPolicy},
+			{ID: "mid", Text: "MMMMMMMMMM", Short: "MM", Priority: 50},
+			{ID: "high", Text: "HHHHHHHHHH", Short: "HH", Priority: 90, Truncate: "shorten"},
+		}
+	}
+	tmpl := "{low?suffix= }{mid?suffix= }{high}"
+	cases := []struct {
+		name   string
+		policy string
+		max    int
+		want   string
+	}{
+		{"fits", "", 32, "LLLLLLLLLL MMMMMMMMMM HHHHHHHHHH"},
+		{"drops lowest with its prefix", "", 25, "MMMMMMMMMM HHHHHHHHHH"},
+		{"drops in priority order", "", 20, "HHHHHHHHHH"},
+		{"shortens what cannot drop", "", 8, "HH"},
+		{"shorten policy is never dropped", "shorten", 25, "LLLLLLLLLL HHHHHHHHHH"},
+		{"short forms before ellipsis", "shorten", 16, "LL HHHHHHHHHH"},
+		{"ellipsizes after short forms", "shorten", 4, "L..."},
+		{"never keeps the full text", "never", 16, "LLLLLLLLLL HH"},
+		{"last resort cuts the line", "never", 8, "LLLLL..."},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Line(tmpl, segs(tc.policy), plainPalette(), tc.max)
+			if got != tc.want {
+				t.Errorf("Line = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDroppedSegmentSkipsElse(t *testing.T) {
+	segs := []types.Segment{
+		{ID: "low", Text: "LLLLLLLLLL", Priority: 10},
+		{ID: "high", Text: "HHHH", Priority: 90},
+	}
+	got := Line("{low?else=none&suffix= }{high}", segs, plainPalette(), 8)
+	if got != "HHHH" {
+		t.Errorf("Line = %q, want %q", got, "HHHH")
+	}
+}
+
 func TestTruncateCountsVisibleRunesNotANSI(t *testing.T) {
 	segs := []types.Segment{
 		{ID: "low", Text: "LLLLLLLLLL", Style: "dim", Priority: 10},
@@ -59,6 +105,35 @@ func TestTruncateCountsVisibleRunesNotANSI(t *testing.T) {
 	}
 }
 
+func TestHyperlinks(t *testing.T) {
+	segs := []types.Segment{
+		{ID: "git", Text: "feature/login", Short: "login", Link: "https://x.test/tree/feature/login", Priority: 60},
+		{ID: "pr", Text: "#7", Link: "https://x.test/pull/7", Priority: 58, Truncate: "never"},
+	}
+	open := func(u string) string { return "\x1b]8;;" + u + "\x1b\\" }
+
+	if got := Line("{git} {pr}", segs, plainPalette(), 0); got != "feature/login #7" {
+		t.Errorf("links without [theme] hyperlinks: %q", got)
+	}
+
+	pal := palette.From(&config.Config{Theme: config.ThemeConfig{Hyperlinks: true}})
+	got := Line("{git?prefix=on } {pr}", segs, pal, 0)
+	want := "on " + open(segs[0].Link) + "feature/login" + palette.LinkEnd + " " + open(segs[1].Link) + "#7" + palette.LinkEnd
+	if got != want {
+		t.Errorf("Line =\n%q\nwant\n%q", got, want)
+	}
+
+	// Links take no columns, and cutting the line never leaves one open.
+	if got := Line("{git} {pr}", segs, pal, 16); visibleLen(got) != 16 || !strings.Contains(got, "feature/login") {
+		t.Errorf("Line at 16 = %q", got)
+	}
+	segs[0].Truncate = "never"
+	got = Line("{git} {pr}", segs, pal, 5)
+	if want := open(segs[0].Link) + "fe" + palette.LinkEnd + "..."; got != want {
+		t.Errorf("Line at 5 = %q, want %q", got, want)
+	}
+}
+
 func TestEffectiveMaxLen(t *testing.T) {
 	cases := []struct {
 		name       string
@@ -89,3 +164,40 @@ func TestNoTruncationWhenDisabled(t *testing.T) {
 		t.Errorf("len = %d, want 300 untouched", len(got))
 	}
 }
+
+func TestLinesTruncateIndependently(t *testing.T) {
+	segs := []types.Segment{
+		{ID: "a", Text: "AAAAAAAAAA", Priority: 10, Truncate: "shorten"},
+		{ID: "b", Text: "BBBBBBBBBB", Priority: 90, Truncate: "never"},
+		{ID: "c", Text: "CCCC", Priority: 50},
+	}
+	got := Lines([]string{"{a} {b}", "{c}"}, segs, plainPalette(), 15)
+	want := "A... BBBBBBBBBB\nCCCC"
+	if got != want {
+		t.Errorf("Lines = %q, want %q", got, want)
+	}
+}
+
+func TestLinesDropsEmptyLine(t *testing.T) {
+	segs := []types.Segment{{ID: "a", Text: "A", Priority: 50}}
+	got := Lines([]string{"{a}", "{missing?prefix= } "}, segs, plainPalette(), 0)
+	if got != "A" {
+		t.Errorf("Lines = %q, want %q", got, "A")
+	}
+}
+
+func TestThemeSegmentStyle(t *testing.T) {
+	pal := palette.From(&config.Config{Theme: config.ThemeConfig{
+		ANSI: true, Depth: "256",
+		Segments: map[string]string{"a": "bold"},
+	}})
+	segs := []types.Segment{
+		{ID: "a", Text: "A", Style: "dim", Priority: 50},
+		{ID: "b", Text: "B", Style: "dim", Priority: 50},
+	}
+	got := Line("{a}{b}{a?style=red}", segs, pal, 0)
+	want := palette.Bold + "A" + palette.Reset + palette.Dim + "B" + palette.Reset + palette.Red + "A" + palette.Reset
+	if got != want {
+		t.Errorf("Line = %q, want %q", got, want)
+	}
+}
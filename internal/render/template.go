@@ -0,0 +1,220 @@
+package render
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/hergert/ccsl/internal/types"
+)
+
+// Template syntax:
+//
+//	{id}                      segment text, or nothing when it is empty
+//	{id?prefix= &suffix=,}    text around the segment, only when it has content
+//	{id?else=no-pr}           shown instead when the segment is empty
+//	{id?style=bold}           overrides the segment's own style
+//	{id?form=short}           the segment's compact (or "long") form, when it
+//	                          has one
+//	{[ · {cost}{ratelimit}]}  literal text inside shows only if a segment in
+//	                          the group has content; groups nest
+//
+// Options are URL query encoded ("%20" or "+" for a space, "%26" for "&").
+type Template struct {
+	nodes []node
+}
+
+type node struct {
+	text string // literal text, when id and group are empty
+
+	id     string
+	prefix string
+	suffix string
+	orElse string
+	style  string
+	form   string
+
+	group []node
+}
+
+var idRe = regexp.MustCompile(`^[-\w:.]+$`)
+
+// Parse reads a template. It always returns a usable Template: anything it
+// can't make sense of is kept as literal text, and the first such problem is
+// returned as the error so `ccsl doctor` can point at it.
+func Parse(s string) (*Template, error) {
+	p := &parser{src: s}
+	nodes, _ := p.parse(0)
+	return &Template{nodes: nodes}, p.err
+}
+
+// IDs lists the segments the template refers to, in order of appearance.
+func (t *Template) IDs() []string {
+	seen := make(map[string]bool)
+	var ids []string
+	var walk func([]node)
+	walk = func(nodes []node) {
+		for _, n := range nodes {
+			if n.id != "" && !seen[n.id] {
+				seen[n.id] = true
+				ids = append(ids, n.id)
+			}
+			walk(n.group)
+		}
+	}
+	walk(t.nodes)
+	return ids
+}
+
+// segments indexes segments by ID with the template's form= options
+// applied. A segment placed twice takes the form of its first placement.
+func (t *Template) segments(segments []types.Segment) map[string]types.Segment {
+	segMap := make(map[string]types.Segment)
+	for _, seg := range segments {
+		segMap[seg.ID] = seg
+	}
+
+	seen := make(map[string]bool)
+	var walk func([]node)
+	walk = func(nodes []node) {
+		for _, n := range nodes {
+			walk(n.group)
+			seg, ok := segMap[n.id]
+			if !ok || seen[n.id] {
+				continue
+			}
+			seen[n.id] = true
+			switch {
+			case n.form == "short" && seg.Short != "":
+				seg.Text, seg.Short = seg.Short, ""
+			case n.form == "long" && seg.Long != "":
+				seg.Text = seg.Long
+			}
+			segMap[n.id] = seg
+		}
+	}
+	walk(t.nodes)
+	return segMap
+}
+
+type parser struct {
+	src string
+	pos int
+	err error
+}
+
+func (p *parser) fail(at int, format string, args ...any) {
+	if p.err == nil {
+		p.err = fmt.Errorf("column %d: %s", at+1, fmt.Sprintf(format, args...))
+	}
+}
+
+// parse reads nodes until the end of input or, inside a group, its "]}";
+// closed reports which.
+func (p *parser) parse(depth int) (nodes []node, closed bool) {
+	var lit strings.Builder
+	flush := func() {
+		if lit.Len() > 0 {
+			nodes = append(nodes, node{text: lit.String()})
+			lit.Reset()
+		}
+	}
+
+	for p.pos < len(p.src) {
+		rest := p.src[p.pos:]
+		switch {
+		case strings.HasPrefix(rest, "{["):
+			flush()
+			start := p.pos
+			p.pos += 2
+			group, closed := p.parse(depth + 1)
+			if !closed {
+				p.fail(start, "unclosed {[")
+			}
+			nodes = append(nodes, node{group: group})
+		case strings.HasPrefix(rest, "]}"):
+			if depth > 0 {
+				p.pos += 2
+				flush()
+				return nodes, true
+			}
+			p.fail(p.pos, "]} without a matching {[")
+			lit.WriteString("]}")
+			p.pos += 2
+		case rest[0] == '{':
+			if n, ok := p.placeholder(); ok {
+				flush()
+				nodes = append(nodes, n)
+			} else {
+				lit.WriteString(p.src[p.pos:p.end()])
+				p.pos = p.end()
+			}
+		default:
+			next := strings.IndexAny(rest, "{]")
+			if next < 0 {
+				next = len(rest)
+			} else if next == 0 {
+				next = 1 // a ']' not closing a group
+			}
+			lit.WriteString(rest[:next])
+			p.pos += next
+		}
+	}
+	flush()
+	return nodes, false
+}
+
+// end is where a broken placeholder at pos ends: its '}', or the end of
+// input.
+func (p *parser) end() int {
+	if i := strings.IndexByte(p.src[p.pos:], '}'); i >= 0 {
+		return p.pos + i + 1
+	}
+	return len(p.src)
+}
+
+// placeholder reads {id?options} at pos, advancing past it when valid.
+func (p *parser) placeholder() (node, bool) {
+	start := p.pos
+	closeAt := strings.IndexByte(p.src[start:], '}')
+	if closeAt < 0 {
+		p.fail(start, "unclosed {")
+		return node{}, false
+	}
+	body := p.src[start+1 : start+closeAt]
+	id, query, hasQuery := strings.Cut(body, "?")
+	if !idRe.MatchString(id) {
+		p.fail(start, "invalid segment name %q", id)
+		return node{}, false
+	}
+
+	n := node{id: id}
+	if hasQuery {
+		vals, err := url.ParseQuery(query)
+		if err != nil {
+			p.fail(start, "{%s}: %v", body, err)
+			return node{}, false
+		}
+		for key := range vals {
+			switch key {
+			case "prefix", "suffix", "else", "style":
+			case "form":
+				if f := vals.Get(key); f != "short" && f != "long" {
+					p.fail(start, "{%s}: unknown form %q (want short or long)", id, f)
+					return node{}, false
+				}
+			default:
+				p.fail(start, "{%s}: unknown option %q", id, key)
+				return node{}, false
+			}
+		}
+		n.prefix = vals.Get("prefix")
+		n.suffix = vals.Get("suffix")
+		n.orElse = vals.Get("else")
+		n.style = vals.Get("style")
+		n.form = vals.Get("form")
+	}
+	p.pos = start + closeAt + 1
+	return n, true
+}
@@ -0,0 +1,117 @@
+package render
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hergert/ccsl/internal/palette"
+	"github.com/hergert/ccsl/internal/types"
+)
+
+func TestTemplateRendering(t *testing.T) {
+	segs := []types.Segment{
+		{ID: "model", Text: "Opus (1M)", Short: "Opus", Priority: 90},
+		{ID: "cost", Text: "$1", Priority: 50},
+		{ID: "cwd", Text: "ccsl", Short: "~/s/ccsl", Long: "~/src/ccsl", Priority: 80},
+	}
+	cases := []struct {
+		name string
+		tmpl string
+		want string
+	}{
+		{"else when empty", "{pr?else=no-pr}", "no-pr"},
+		{"else ignored with content", "{cost?else=free}", "$1"},
+		{"else skips prefix", "{pr?prefix=PR&else=none}", "none"},
+		{"group shown", "{model?form=short}{[ · {cost}{ratelimit}]}", "Opus · $1"},
+		{"group hidden", "{model?form=short}{[ · {pr}{ratelimit}]}", "Opus"},
+		{"else does not open group", "{model?form=short}{[ · {pr?else=x}]}", "Opus"},
+		{"default form", "{model} {cwd}", "Opus (1M) ccsl"},
+		{"long form", "{cwd?form=long}", "~/src/ccsl"},
+		{"no form falls back to text", "{cost?form=short}{cost?form=long}", "$1$1"},
+		{"nested groups", "{[<{[({pr})]}{cost}>]}", "<$1>"},
+		{"suffix with bracket", "{cost?suffix=]}", "$1]"},
+		{"plus is space", "{cost?prefix=a+b}", "a b$1"},
+		{"literal braces kept", "a{ b }c", "a{ b }c"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Line(tc.tmpl, segs, plainPalette(), 0); got != tc.want {
+				t.Errorf("Line(%q) = %q, want %q", tc.tmpl, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTemplateStyleOverride(t *testing.T) {
+	segs := []types.Segment{{ID: "cost", Text: "$1", Style: "dim", Priority: 50}}
+	got := Line("{cost?style=red}{pr?else=-&style=bold}", segs, ansiPalette(), 0)
+	want := palette.Red + "$1" + palette.Reset + palette.Bold + "-" + palette.Reset
+	if got != want {
+		t.Errorf("Line = %q, want %q", got, want)
+	}
+}
+
+func TestTemplateGroupTruncation(t *testing.T) {
+	segs := []types.Segment{
+		{ID: "low", Text: "LLLLLLLLLL", Priority: 10},
+		{ID: "high", Text: "HHHHHHHHHH", Priority: 90},
+	}
+	got := Line("{high}{[ · {low}]}", segs, plainPalette(), 18)
+	if want := "HHHHHHHHHH"; got != want {
+		t.Errorf("Line = %q, want %q", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		tmpl string
+		want string
+	}{
+		{"{model", "column 1: unclosed {"},
+		{"x {[ {model}", "column 3: unclosed {["},
+		{"{model}]}", "column 8: ]} without a matching {["},
+		{"{model?prefx= }", `unknown option "prefx"`},
+		{"{ model }", `invalid segment name " model "`},
+		{"{model?prefix=%zz}", "invalid URL escape"},
+		{"{model?form=tiny}", `unknown form "tiny"`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.tmpl, func(t *testing.T) {
+			_, err := Parse(tc.tmpl)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("Parse(%q) err = %v, want %q", tc.tmpl, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestTemplateFormsUnderPressure(t *testing.T) {
+	segs := []types.Segment{
+		{ID: "cwd", Text: "ccsl", Short: "~/s/ccsl", Long: "~/src/ccsl", Priority: 80, Truncate: "shorten"},
+	}
+	if got := Line("{cwd?form=long}", segs, plainPalette(), 8); got != "~/s/ccsl" {
+		t.Errorf("Line = %q, want %q", got, "~/s/ccsl")
+	}
+	if got := Line("{cwd?form=short}", segs, plainPalette(), 0); got != "~/s/ccsl" {
+		t.Errorf("Line = %q, want %q", got, "~/s/ccsl")
+	}
+}
+
+func TestParseKeepsBrokenPartsLiteral(t *testing.T) {
+	segs := []types.Segment{{ID: "model", Text: "Opus", Priority: 90}}
+	if got := Line("{model?prefx= } {model}", segs, plainPalette(), 0); got != "{model?prefx= } Opus" {
+		t.Errorf("Line = %q", got)
+	}
+}
+
+func TestTemplateIDs(t *testing.T) {
+	tpl, err := Parse("{model}{[ · {cost}{[{ratelimit}]}]} {model} {pr?else=none}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"model", "cost", "ratelimit", "pr"}
+	if got := tpl.IDs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("IDs = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,128 @@
+package render
+
+import (
+	"sort"
+
+	"github.com/hergert/ccsl/internal/types"
+)
+
+// Truncation policies (types.Segment.Truncate). "drop", the default, lets a
+// segment be removed outright; "shorten" keeps it but lets it switch to its
+// short form and then be ellipsized; "never" leaves it alone.
+const (
+	policyDrop    = "drop"
+	policyShorten = "shorten"
+	policyNever   = "never"
+)
+
+func policy(seg types.Segment) string {
+	switch seg.Truncate {
+	case policyShorten, policyNever:
+		return seg.Truncate
+	}
+	return policyDrop
+}
+
+// form is how far a segment has been degraded to make its line fit.
+type form struct {
+	dropped bool
+	short   bool
+	width   int // ellipsize to this many columns; 0 for no limit
+}
+
+type forms map[string]form
+
+// text is seg's text in form f.
+func (f form) text(seg types.Segment) string {
+	text := seg.Text
+	if f.short && seg.Short != "" {
+		text = seg.Short
+	}
+	if f.width > 0 && visibleLen(text) > f.width {
+		text = truncateWidth(text, f.width-3) + "..."
+	}
+	return text
+}
+
+// fit re-renders the line with the segments in ids degraded step by step
+// until it fits in maxLen columns, stopping as soon as it does:
+//
+//  1. drop whole segments, lowest priority first, keeping at least one;
+//  2. switch the rest, lowest priority first, to their short forms;
+//  3. ellipsize them, lowest priority first.
+//
+// Each segment's policy limits which steps apply to it. The result may
+// still be too long; the caller decides the last resort.
+func fit(segs map[string]types.Segment, ids []string, maxLen int, render func(forms) string) (string, forms) {
+	f := forms{}
+	out := render(f)
+	fits := func() bool { return visibleLen(out) <= maxLen }
+	if maxLen <= 0 || fits() {
+		return out, f
+	}
+
+	var order []string
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if seg, ok := segs[id]; ok && seg.Text != "" && !seen[id] {
+			seen[id] = true
+			order = append(order, id)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return segs[order[i]].Priority < segs[order[j]].Priority
+	})
+
+	kept := len(order)
+	for _, id := range order {
+		if kept == 1 {
+			break
+		}
+		if policy(segs[id]) != policyDrop {
+			continue
+		}
+		f[id] = form{dropped: true}
+		kept--
+		if out = render(f); fits() {
+			return out, f
+		}
+	}
+
+	for _, id := range order {
+		seg := segs[id]
+		if f[id].dropped || policy(seg) == policyNever || seg.Short == "" ||
+			visibleLen(seg.Short) >= visibleLen(seg.Text) {
+			continue
+		}
+		f[id] = form{short: true}
+		if out = render(f); fits() {
+			return out, f
+		}
+	}
+
+	for _, id := range order {
+		seg := segs[id]
+		if f[id].dropped || policy(seg) == policyNever {
+			continue
+		}
+		keep := visibleLen(f[id].text(seg)) - (visibleLen(out) - maxLen)
+		if keep <= 3 {
+			continue // not even one column left besides the "..."
+		}
+		g := f[id]
+		g.width = keep
+		f[id] = g
+		if out = render(f); fits() {
+			return out, f
+		}
+	}
+	return out, f
+}
+
+// ellipsize chops a line that is still too long after fit.
+func ellipsize(s string, maxLen int) string {
+	if maxLen <= 3 {
+		return "..."[:maxLen]
+	}
+	return truncateWidth(s, maxLen-3) + "..."
+}
@@ -0,0 +1,215 @@
+package render
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hergert/ccsl/internal/palette"
+)
+
+// Terminal column widths, approximating what terminals do with UAX #11
+// (East Asian Width) and UAX #29 grapheme clusters: a cluster is a base
+// character plus whatever attaches to it (combining marks, variation
+// selectors, skin tones, ZWJ-joined emoji, flag pairs) and takes the width
+// of its base, or 2 once emoji presentation (U+FE0F) is requested.
+
+var ansiPrefixRe = regexp.MustCompile(`^\x1b\[[0-9;]*m`)
+
+const (
+	zwj  = 0x200D
+	vs16 = 0xFE0F // emoji presentation selector
+)
+
+// Wide (W) and Fullwidth (F) ranges, merged where adjacent.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F}, {0x231A, 0x231B}, {0x2329, 0x232A}, {0x23E9, 0x23EC},
+	{0x23F0, 0x23F0}, {0x23F3, 0x23F3}, {0x25FD, 0x25FE}, {0x2614, 0x2615},
+	{0x2648, 0x2653}, {0x267F, 0x267F}, {0x2693, 0x2693}, {0x26A1, 0x26A1},
+	{0x26AA, 0x26AB}, {0x26BD, 0x26BE}, {0x26C4, 0x26C5}, {0x26CE, 0x26CE},
+	{0x26D4, 0x26D4}, {0x26EA, 0x26EA}, {0x26F2, 0x26F3}, {0x26F5, 0x26F5},
+	{0x26FA, 0x26FA}, {0x26FD, 0x26FD}, {0x2705, 0x2705}, {0x270A, 0x270B},
+	{0x2728, 0x2728}, {0x274C, 0x274C}, {0x274E, 0x274E}, {0x2753, 0x2755},
+	{0x2757, 0x2757}, {0x2795, 0x2797}, {0x27B0, 0x27B0}, {0x27BF, 0x27BF},
+	{0x2B1B, 0x2B1C}, {0x2B50, 0x2B50}, {0x2B55, 0x2B55}, {0x2E80, 0x303E},
+	{0x3041, 0x33FF}, {0x3400, 0x4DBF}, {0x4E00, 0x9FFF}, {0xA000, 0xA4CF},
+	{0xA960, 0xA97F}, {0xAC00, 0xD7A3}, {0xF900, 0xFAFF}, {0xFE10, 0xFE19},
+	{0xFE30, 0xFE6F}, {0xFF00, 0xFF60}, {0xFFE0, 0xFFE6}, {0x16FE0, 0x16FE4},
+	{0x17000, 0x18CFF}, {0x1B000, 0x1B2FF}, {0x1F004, 0x1F004}, {0x1F0CF, 0x1F0CF},
+	{0x1F18E, 0x1F18E}, {0x1F191, 0x1F19A}, {0x1F200, 0x1F251}, {0x1F300, 0x1F320},
+	{0x1F32D, 0x1F335}, {0x1F337, 0x1F37C}, {0x1F37E, 0x1F393}, {0x1F3A0, 0x1F3CA},
+	{0x1F3CF, 0x1F3D3}, {0x1F3E0, 0x1F3F0}, {0x1F3F4, 0x1F3F4}, {0x1F3F8, 0x1F43E},
+	{0x1F440, 0x1F440}, {0x1F442, 0x1F4FC}, {0x1F4FF, 0x1F53D}, {0x1F54B, 0x1F54E},
+	{0x1F550, 0x1F567}, {0x1F57A, 0x1F57A}, {0x1F595, 0x1F596}, {0x1F5A4, 0x1F5A4},
+	{0x1F5FB, 0x1F64F}, {0x1F680, 0x1F6C5}, {0x1F6CC, 0x1F6CC}, {0x1F6D0, 0x1F6D2},
+	{0x1F6D5, 0x1F6D7}, {0x1F6DC, 0x1F6DF}, {0x1F6EB, 0x1F6EC}, {0x1F6F4, 0x1F6FC},
+	{0x1F7E0, 0x1F7EB}, {0x1F7F0, 0x1F7F0}, {0x1F90C, 0x1F93A}, {0x1F93C, 0x1F945},
+	{0x1F947, 0x1F9FF}, {0x1FA70, 0x1FAFF}, {0x20000, 0x2FFFD}, {0x30000, 0x3FFFD},
+}
+
+func isWide(r rune) bool {
+	lo, hi := 0, len(wideRanges)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		switch {
+		case r < wideRanges[mid][0]:
+			hi = mid
+		case r > wideRanges[mid][1]:
+			lo = mid + 1
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// extends reports whether r attaches to the preceding character.
+func extends(r rune) bool {
+	switch {
+	case r >= 0xFE00 && r <= 0xFE0F, // variation selectors
+		r >= 0x1F3FB && r <= 0x1F3FF, // skin tone modifiers
+		r >= 0xE0020 && r <= 0xE007F, // tag characters (subdivision flags)
+		r >= 0xE0100 && r <= 0xE01EF, // variation selectors supplement
+		r >= 0x1160 && r <= 0x11FF:   // Hangul medial vowels and final consonants
+		return true
+	}
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc)
+}
+
+func isRegional(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x20 || r >= 0x7F && r < 0xA0:
+		return 0
+	case extends(r) || r == zwj || unicode.Is(unicode.Cf, r):
+		return 0
+	case isWide(r):
+		return 2
+	}
+	return 1
+}
+
+// nextCluster returns the byte length and column width of the grapheme
+// cluster at the start of s.
+func nextCluster(s string) (size, width int) {
+	r, size := utf8.DecodeRuneInString(s)
+	width = runeWidth(r)
+	if isRegional(r) {
+		if r2, n := utf8.DecodeRuneInString(s[size:]); isRegional(r2) {
+			return size + n, 2
+		}
+		return size, 1
+	}
+	for size < len(s) {
+		r2, n := utf8.DecodeRuneInString(s[size:])
+		switch {
+		case r2 == zwj:
+			size += n
+			if size < len(s) {
+				_, n = utf8.DecodeRuneInString(s[size:])
+				size += n
+			}
+		case r2 == vs16:
+			size += n
+			if width == 1 {
+				width = 2
+			}
+		case extends(r2):
+			size += n
+		default:
+			return size, width
+		}
+	}
+	return size, width
+}
+
+// visibleLen is the number of terminal columns s takes, ignoring ANSI.
+func visibleLen(s string) int {
+	w := 0
+	for s != "" {
+		if n := ansiLen(s); n > 0 {
+			s = s[n:]
+			continue
+		}
+		size, cw := nextCluster(s)
+		w += cw
+		s = s[size:]
+	}
+	return w
+}
+
+// truncateWidth keeps as many whole clusters of s as fit in n columns,
+// passing ANSI sequences through untouched. A hyperlink cut short is
+// closed, so the link never runs on into what follows.
+func truncateWidth(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+
+	var result strings.Builder
+	visible := 0
+	i := 0
+	inLink := false
+
+	for i < len(s) && visible < n {
+		if esc := ansiLen(s[i:]); esc > 0 {
+			if open, ok := linkTarget(s[i : i+esc]); ok {
+				inLink = open
+			}
+			result.WriteString(s[i : i+esc])
+			i += esc
+			continue
+		}
+
+		size, w := nextCluster(s[i:])
+		if visible+w > n {
+			break
+		}
+		result.WriteString(s[i : i+size])
+		i += size
+		visible += w
+	}
+
+	if inLink {
+		result.WriteString(palette.LinkEnd)
+	}
+	return result.String()
+}
+
+// ansiLen is the length of the SGR sequence, or OSC sequence such as a
+// hyperlink, at the start of s, or 0.
+func ansiLen(s string) int {
+	switch {
+	case strings.HasPrefix(s, "\x1b["):
+		if loc := ansiPrefixRe.FindStringIndex(s); loc != nil {
+			return loc[1]
+		}
+	case strings.HasPrefix(s, "\x1b]"):
+		// Terminated by ST (ESC \) or BEL.
+		for i := 2; i < len(s); i++ {
+			switch {
+			case s[i] == '\a':
+				return i + 1
+			case s[i] == '\x1b' && i+1 < len(s) && s[i+1] == '\\':
+				return i + 2
+			}
+		}
+	}
+	return 0
+}
+
+// linkTarget reports whether seq is an OSC 8 hyperlink and, if so, whether
+// it opens a link (has a URL) rather than closing one.
+func linkTarget(seq string) (open, ok bool) {
+	rest, ok := strings.CutPrefix(seq, "\x1b]8;")
+	if !ok {
+		return false, false
+	}
+	_, uri, _ := strings.Cut(rest, ";")
+	uri = strings.TrimSuffix(strings.TrimSuffix(uri, "\x1b\\"), "\a")
+	return uri != "", true
+}
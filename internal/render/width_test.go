@@ -0,0 +1,100 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/hergert/ccsl/internal/palette"
+	"github.com/hergert/ccsl/internal/types"
+)
+
+func TestVisibleLen(t *testing.T) {
+	cases := []struct {
+		name string
+		s    string
+		want int
+	}{
+		{"ascii", "main", 4},
+		{"cjk", "漢字", 4},
+		{"cjk branch", "feat/日本語", 11},
+		{"hangul syllables", "한국", 4},
+		{"hangul jamo sequence", "각", 2},
+		{"halfwidth katakana", "ｱｲ", 2},
+		{"fullwidth latin", "ＡＢ", 4},
+		{"emoji", "👍", 2},
+		{"emoji skin tone", "👍🏽", 2},
+		{"zwj family", "👨‍👩‍👧", 2},
+		{"zwj then text", "👩‍💻dev", 5},
+		{"flag", "🇯🇵", 2},
+		{"lone regional indicator", "🇯", 1},
+		{"combining acute", "été", 3},
+		{"warning sign text", "⚠", 1},
+		{"warning sign emoji", "⚠️", 2},
+		{"ansi ignored", palette.Red + "漢" + palette.Reset + "x", 3},
+		{"zero width space", "a\u200bb", 2},
+		{"hyperlink ignored", "\x1b]8;;https://x.test/漢\x1b\\main" + palette.LinkEnd, 4},
+		{"hyperlink with bel", "\x1b]8;;https://x.test\amain\x1b]8;;\a", 4},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := visibleLen(tc.s); got != tc.want {
+				t.Errorf("visibleLen(%q) = %d, want %d", tc.s, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTruncateWidth(t *testing.T) {
+	cases := []struct {
+		name string
+		s    string
+		n    int
+		want string
+	}{
+		{"ascii", "abcdef", 3, "abc"},
+		{"wide not split", "漢字漢字", 3, "漢"},
+		{"wide exact", "漢字漢字", 4, "漢字"},
+		{"zwj kept whole", "👨‍👩‍👧x", 2, "👨‍👩‍👧"},
+		{"zwj too wide", "👨‍👩‍👧x", 1, ""},
+		{"combining kept", "éée", 2, "éé"},
+		{"flag kept whole", "🇯🇵🇫🇷", 3, "🇯🇵"},
+		{"ansi passed through", palette.Bold + "漢字" + palette.Reset, 2, palette.Bold + "漢"},
+		{"cut link closed", "\x1b]8;;u\x1b\\abcdef" + palette.LinkEnd + "gh", 3, "\x1b]8;;u\x1b\\abc" + palette.LinkEnd},
+		{"whole link closed", "\x1b]8;;u\x1b\\abc" + palette.LinkEnd + "gh", 3, "\x1b]8;;u\x1b\\abc" + palette.LinkEnd},
+		{"closed link left alone", "\x1b]8;;u\x1b\\ab" + palette.LinkEnd + "cdef", 3, "\x1b]8;;u\x1b\\ab" + palette.LinkEnd + "c"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := truncateWidth(tc.s, tc.n); got != tc.want {
+				t.Errorf("truncateWidth(%q, %d) = %q, want %q", tc.s, tc.n, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLineTruncatesByColumns(t *testing.T) {
+	cases := []struct {
+		name string
+		low  string
+		max  int
+		want string
+	}{
+		{"cjk segment", "漢字漢字漢字", 10, "漢... HHHH"},
+		{"emoji segment", "👍👍👍👍👍👍", 10, "👍... HHHH"},
+		{"combining segment", "ééééééé", 10, "éé... HHHH"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			segs := []types.Segment{
+				{ID: "low", Text: tc.low, Priority: 10, Truncate: "shorten"},
+				{ID: "high", Text: "HHHH", Priority: 90, Truncate: "never"},
+			}
+			got := Line("{low} {high}", segs, plainPalette(), tc.max)
+			if got != tc.want {
+				t.Errorf("Line = %q, want %q", got, tc.want)
+			}
+			if w := visibleLen(got); w > tc.max {
+				t.Errorf("width %d > %d", w, tc.max)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hergert/ccsl/builtin/cwd"
+	"github.com/hergert/ccsl/internal/config"
+	"github.com/hergert/ccsl/internal/render"
+)
+
+// pluginCmd builds the process of an exec or daemon plugin: command and args
+// expanded, the environment shaped by env, env_clear and env_allow with vars
+// added last, running in dir (ccsl's own directory when empty).
+func pluginCmd(ctx context.Context, pcfg config.PluginConfig, dir string, vars ...string) *exec.Cmd {
+	args := make([]string, len(pcfg.Args))
+	for i, a := range pcfg.Args {
+		args[i] = expand(a)
+	}
+	cmd := exec.CommandContext(ctx, expand(pcfg.Command), args...)
+	if pcfg.EnvClear {
+		for _, name := range pcfg.EnvAllow {
+			if v, ok := os.LookupEnv(name); ok {
+				cmd.Env = append(cmd.Env, name+"="+v)
+			}
+		}
+	} else {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(append(cmd.Env, configEnv(pcfg)...), vars...)
+	cmd.Dir = dir
+	return cmd
+}
+
+// configEnv is the env table as KEY=value pairs, values expanded, in a
+// stable order.
+func configEnv(pcfg config.PluginConfig) []string {
+	env := make([]string, 0, len(pcfg.Env))
+	for k, v := range pcfg.Env {
+		env = append(env, k+"="+expand(v))
+	}
+	sort.Strings(env)
+	return env
+}
+
+// execVars are the CCSL_* variables an exec plugin runs with: the protocol
+// version, the segment id it is configured as, the Claude session and the
+// columns the status line is cut to.
+func execVars(id string, ctxObj map[string]any, cfg *config.Config) []string {
+	vars := []string{"CCSL_PROTOCOL=" + protocolVersion, "CCSL_SEGMENT_ID=" + id}
+	if s, ok := ctxObj["session_id"].(string); ok && s != "" {
+		vars = append(vars, "CCSL_SESSION_ID="+s)
+	}
+	if n := columns(cfg); n > 0 {
+		vars = append(vars, "CCSL_COLUMNS="+strconv.Itoa(n))
+	}
+	return vars
+}
+
+// execDir is where an exec plugin runs: cwd if configured, else the
+// statusline's workspace.current_dir.
+func execDir(pcfg config.PluginConfig, ctxObj map[string]any) string {
+	if pcfg.Cwd != "" {
+		return expand(pcfg.Cwd)
+	}
+	return cwd.Parse(ctxObj).Path
+}
+
+// columns is the width the status line is truncated to, 0 for none.
+func columns(cfg *config.Config) int {
+	return render.EffectiveMaxLen(cfg.UI.Truncate, os.Getenv("COLUMNS"))
+}
+
+// daemonKey tells daemons apart: the resolved command line, its directory
+// and the environment the config asks for. The inherited environment is
+// left out, since it differs between the sessions sharing a daemon.
+func daemonKey(cmd *exec.Cmd, pcfg config.PluginConfig) []string {
+	key := append([]string{cmd.Path, "dir=" + cmd.Dir}, cmd.Args[1:]...)
+	key = append(key, configEnv(pcfg)...)
+	if pcfg.EnvClear {
+		key = append(key, "env_clear="+strings.Join(pcfg.EnvAllow, ","))
+	}
+	return key
+}
+
+// expand replaces a leading ~ with the home directory and $VAR or ${VAR}
+// with its value (empty when unset), as a shell would.
+func expand(s string) string {
+	if s == "~" || strings.HasPrefix(s, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			s = filepath.Join(home, s[1:])
+		}
+	}
+	return os.ExpandEnv(s)
+}
@@ -0,0 +1,103 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hergert/ccsl/internal/config"
+)
+
+func TestExpand(t *testing.T) {
+	t.Setenv("HOME", "/home/ann")
+	t.Setenv("CCSL_TEST_DIR", "/opt/seg")
+	cases := map[string]string{
+		"~":                    "/home/ann",
+		"~/bin/myseg":          "/home/ann/bin/myseg",
+		"~ann/bin":             "~ann/bin",
+		"$CCSL_TEST_DIR/run":   "/opt/seg/run",
+		"${CCSL_TEST_DIR}.d":   "/opt/seg.d",
+		"--ctx=$CCSL_UNSET_XY": "--ctx=",
+		"plain":                "plain",
+	}
+	for in, want := range cases {
+		if got := expand(in); got != want {
+			t.Errorf("expand(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// env returns an environment as a map, the last value of a name winning
+// as it does for the child.
+func env(kvs []string) map[string]string {
+	m := make(map[string]string)
+	for _, kv := range kvs {
+		k, v, _ := strings.Cut(kv, "=")
+		m[k] = v
+	}
+	return m
+}
+
+func TestPluginCmdEnv(t *testing.T) {
+	t.Setenv("HOME", "/home/ann")
+	t.Setenv("CCSL_TEST_KEEP", "kept")
+	t.Setenv("CCSL_TEST_DROP", "dropped")
+
+	pcfg := config.PluginConfig{
+		Command: "~/bin/seg",
+		Args:    []string{"--home=$HOME"},
+		Env:     map[string]string{"KUBECONFIG": "~/.kube/work", "CCSL_SEGMENT_ID": "spoofed"},
+	}
+	cmd := pluginCmd(context.Background(), pcfg, "/work", "CCSL_SEGMENT_ID=kube")
+	if want := []string{"/home/ann/bin/seg", "--home=/home/ann"}; !reflect.DeepEqual(cmd.Args, want) {
+		t.Errorf("Args = %q, want %q", cmd.Args, want)
+	}
+	if cmd.Dir != "/work" {
+		t.Errorf("Dir = %q, want /work", cmd.Dir)
+	}
+	e := env(cmd.Env)
+	if e["CCSL_TEST_DROP"] != "dropped" || e["KUBECONFIG"] != "/home/ann/.kube/work" || e["CCSL_SEGMENT_ID"] != "kube" {
+		t.Errorf("inherited env = %v", e)
+	}
+
+	pcfg.EnvClear = true
+	pcfg.EnvAllow = []string{"CCSL_TEST_KEEP", "CCSL_TEST_UNSET"}
+	e = env(pluginCmd(context.Background(), pcfg, "").Env)
+	if _, ok := e["CCSL_TEST_DROP"]; ok {
+		t.Error("env_clear passed on a variable not in env_allow")
+	}
+	if _, ok := e["CCSL_TEST_UNSET"]; ok {
+		t.Error("env_allow created an unset variable")
+	}
+	if e["CCSL_TEST_KEEP"] != "kept" || e["KUBECONFIG"] != "/home/ann/.kube/work" {
+		t.Errorf("cleared env = %v", e)
+	}
+}
+
+func TestExecPluginContext(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "where")
+	body := "#!/bin/sh\ncat >/dev/null\necho \"$CCSL_SEGMENT_ID $CCSL_SESSION_ID $CCSL_COLUMNS $(basename \"$PWD\")\"\n"
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	work := filepath.Join(dir, "project")
+	if err := os.Mkdir(work, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("COLUMNS", "120")
+
+	cfg := &config.Config{
+		UI:     config.UIConfig{Template: "{where}", Truncate: 80},
+		Limits: config.LimitsConfig{PerPluginTimeoutMS: 2000},
+		Plugin: map[string]config.PluginConfig{"where": {Type: "exec", Command: script}},
+	}
+	ctxObj := map[string]any{"session_id": "s1", "workspace": map[string]any{"current_dir": work}}
+	segs := Collect(context.Background(), ctxObj, []byte(`{}`), cfg)
+	if len(segs) != 1 || segs[0].Text != "where s1 80 project" {
+		t.Errorf("Collect = %+v, want text %q", segs, "where s1 80 project")
+	}
+}
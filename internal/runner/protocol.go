@@ -0,0 +1,101 @@
+package runner
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/hergert/ccsl/internal/types"
+)
+
+// Exec plugins see CCSL_PROTOCOL set to the newest protocol ccsl speaks.
+//
+// Version 1 is the first stdout line: plain text, or one segment as a JSON
+// object. A plugin answers in version 2 by printing protocolHeader first;
+// everything after it is one JSON object or an array of them, each a
+// segment that may also carry a color. Array elements with an "id" become
+// sub-segments, placed in templates as {plugin:id}; one without is the
+// plugin's own {plugin}.
+const (
+	protocolVersion = "2"
+	protocolHeader  = "ccsl-protocol: 2"
+)
+
+var subIDRe = regexp.MustCompile(`^[-\w.]+$`)
+
+// wireSegment is a segment as a version 2 plugin writes it.
+type wireSegment struct {
+	types.Segment
+	Color string `json:"color"`
+}
+
+// segment folds color into the style, unless the style is raw ANSI.
+func (w wireSegment) segment() types.Segment {
+	seg := w.Segment
+	if w.Color != "" && !strings.HasPrefix(seg.Style, "\x1b") {
+		seg.Style = strings.TrimSpace(seg.Style + " " + w.Color)
+	}
+	seg.Parts = nil
+	return seg
+}
+
+// parseOutput reads a plugin's stdout in either protocol version.
+func parseOutput(out string) types.Segment {
+	raw := strings.TrimSpace(out)
+	if raw == "" {
+		return types.Segment{}
+	}
+	first, rest, _ := strings.Cut(raw, "\n")
+	if strings.TrimSpace(first) == protocolHeader {
+		return parseV2(rest)
+	}
+
+	var resp types.Segment
+	if json.Unmarshal([]byte(first), &resp) == nil && resp.Text != "" {
+		resp.Parts = nil
+		return resp
+	}
+	return types.Segment{Text: first}
+}
+
+// parseResult reads a daemon's answer: a string of text, or what follows
+// the version 2 header.
+func parseResult(res json.RawMessage) types.Segment {
+	var text string
+	if json.Unmarshal(res, &text) == nil {
+		return types.Segment{Text: text}
+	}
+	return parseV2(string(res))
+}
+
+func parseV2(body string) types.Segment {
+	body = strings.TrimSpace(body)
+	if strings.HasPrefix(body, "{") {
+		var w wireSegment
+		if json.Unmarshal([]byte(body), &w) != nil {
+			return types.Segment{}
+		}
+		return w.segment()
+	}
+
+	var list []wireSegment
+	if json.Unmarshal([]byte(body), &list) != nil {
+		return types.Segment{}
+	}
+	var own types.Segment
+	haveOwn := false
+	seen := make(map[string]bool)
+	for _, w := range list {
+		seg := w.segment()
+		switch {
+		case seg.ID == "":
+			if !haveOwn {
+				own, haveOwn = seg, true
+			}
+		case subIDRe.MatchString(seg.ID) && !seen[seg.ID]:
+			seen[seg.ID] = true
+			own.Parts = append(own.Parts, seg)
+		}
+	}
+	return own
+}
@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hergert/ccsl/internal/config"
+	"github.com/hergert/ccsl/internal/types"
+)
+
+func TestParseOutput(t *testing.T) {
+	cases := []struct {
+		name string
+		out  string
+		want types.Segment
+	}{
+		{"empty", "\n", types.Segment{}},
+		{"v1 text", "85%\nignored\n", types.Segment{Text: "85%"}},
+		{"v1 json", `{"text":"2m","style":"dim","priority":40}`, types.Segment{Text: "2m", Style: "dim", Priority: 40}},
+		{"v1 ignores parts", `{"text":"x","parts":[{"id":"a","text":"y"}]}`, types.Segment{Text: "x"}},
+		{"v1 json without text is text", `{"style":"dim"}`, types.Segment{Text: `{"style":"dim"}`}},
+		{"v2 object", "ccsl-protocol: 2\n{\"text\":\"up\",\"short_text\":\"u\",\"link\":\"https://x.test\",\"color\":\"#ff8800\",\"style\":\"bold\"}",
+			types.Segment{Text: "up", Short: "u", Link: "https://x.test", Style: "bold #ff8800"}},
+		{"v2 object over several lines", "ccsl-protocol: 2\n{\n  \"text\": \"up\"\n}\n", types.Segment{Text: "up"}},
+		{"v2 color with raw ansi style", "ccsl-protocol: 2\n{\"text\":\"up\",\"style\":\"\\u001b[1m\",\"color\":\"red\"}", types.Segment{Text: "up", Style: "\x1b[1m"}},
+		{"v2 array", "ccsl-protocol: 2\n[{\"text\":\"all\"},{\"id\":\"ns\",\"text\":\"default\",\"color\":\"blue\"},{\"id\":\"ctx\",\"text\":\"prod\",\"priority\":70}]",
+			types.Segment{Text: "all", Parts: []types.Segment{
+				{ID: "ns", Text: "default", Style: "blue"},
+				{ID: "ctx", Text: "prod", Priority: 70},
+			}}},
+		{"v2 array skips bad and duplicate ids", "ccsl-protocol: 2\n[{\"id\":\"a:b\",\"text\":\"x\"},{\"id\":\"ns\",\"text\":\"1\"},{\"id\":\"ns\",\"text\":\"2\"}]",
+			types.Segment{Parts: []types.Segment{{ID: "ns", Text: "1"}}}},
+		{"v2 malformed", "ccsl-protocol: 2\n[{\"text\":", types.Segment{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := parseOutput(tc.out); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseOutput = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseResult(t *testing.T) {
+	cases := []struct {
+		res  string
+		want types.Segment
+	}{
+		{`"85%"`, types.Segment{Text: "85%"}},
+		{`{"text":"up","color":"red"}`, types.Segment{Text: "up", Style: "red"}},
+		{`[{"text":"all"},{"id":"ns","text":"web"}]`, types.Segment{Text: "all", Parts: []types.Segment{{ID: "ns", Text: "web"}}}},
+		{`null`, types.Segment{}},
+	}
+	for _, tc := range cases {
+		if got := parseResult(json.RawMessage(tc.res)); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("parseResult(%s) = %+v, want %+v", tc.res, got, tc.want)
+		}
+	}
+}
+
+func TestCollectSubSegments(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "kube")
+	body := `#!/bin/sh
+cat >/dev/null
+[ "$CCSL_PROTOCOL" = 2 ] || { echo v1; exit; }
+echo 'ccsl-protocol: 2'
+echo '[{"id":"ctx","text":"prod","priority":70},{"id":"ns","text":"web"}]'
+`
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		UI:     config.UIConfig{Template: "{kube:ctx}/{kube:ns}{kube:missing}"},
+		Limits: config.LimitsConfig{PerPluginTimeoutMS: 2000},
+		Plugin: map[string]config.PluginConfig{"kube": {Type: "exec", Command: script, Truncate: "never"}},
+	}
+	segs := Collect(context.Background(), map[string]any{}, []byte("{}"), cfg)
+	want := []types.Segment{
+		{ID: "kube:ctx", Text: "prod", Priority: 70, Truncate: "never"},
+		{ID: "kube:ns", Text: "web", Priority: 50, Truncate: "never"},
+	}
+	if !reflect.DeepEqual(segs, want) {
+		t.Errorf("Collect = %+v, want %+v", segs, want)
+	}
+}
@@ -3,15 +3,15 @@ package runner
 import (
 	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"io"
 	"os/exec"
-	"regexp"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/hergert/ccsl/builtin/agent"
+	"github.com/hergert/ccsl/builtin/aws"
 	"github.com/hergert/ccsl/builtin/cloudflare"
 	"github.com/hergert/ccsl/builtin/cost"
 	ctxbuiltin "github.com/hergert/ccsl/builtin/ctx"
@@ -20,12 +20,26 @@ import (
 	"github.com/hergert/ccsl/builtin/effort"
 	"github.com/hergert/ccsl/builtin/gcp"
 	"github.com/hergert/ccsl/builtin/git"
+	"github.com/hergert/ccsl/builtin/k8s"
 	"github.com/hergert/ccsl/builtin/lines"
 	"github.com/hergert/ccsl/builtin/model"
 	"github.com/hergert/ccsl/builtin/pr"
 	"github.com/hergert/ccsl/builtin/ratelimit"
+	"github.com/hergert/ccsl/builtin/since"
+	"github.com/hergert/ccsl/builtin/spend"
+	"github.com/hergert/ccsl/builtin/todos"
+	"github.com/hergert/ccsl/builtin/tokens"
+	"github.com/hergert/ccsl/builtin/tool"
 	"github.com/hergert/ccsl/builtin/worktree"
+	"github.com/hergert/ccsl/internal/cache"
 	"github.com/hergert/ccsl/internal/config"
+	"github.com/hergert/ccsl/internal/daemon"
+	"github.com/hergert/ccsl/internal/forge"
+	"github.com/hergert/ccsl/internal/ledger"
+	"github.com/hergert/ccsl/internal/palette"
+	"github.com/hergert/ccsl/internal/pricing"
+	"github.com/hergert/ccsl/internal/render"
+	"github.com/hergert/ccsl/internal/transcript"
 	"github.com/hergert/ccsl/internal/types"
 )
 
@@ -48,33 +62,26 @@ func (l *limitedWriter) Write(p []byte) (int, error) {
 	return n, err
 }
 
-var segmentRe = regexp.MustCompile(`\{([-\w:.]+)`)
-
 func parseSegments(template string) []string {
-	matches := segmentRe.FindAllStringSubmatch(template, -1)
-	seen := make(map[string]bool)
-	var result []string
-	for _, m := range matches {
-		id := m[1]
-		if !seen[id] {
-			seen[id] = true
-			result = append(result, id)
-		}
-	}
-	return result
+	t, _ := render.Parse(template)
+	return t.IDs()
 }
 
 func Collect(ctx context.Context, ctxObj map[string]any, claudeJSON []byte, cfg *config.Config) []types.Segment {
 
 	segmentIDs := cfg.Plugins.Order
 	if len(segmentIDs) == 0 {
-		segmentIDs = parseSegments(cfg.UI.Template)
+		segmentIDs = parseSegments(strings.Join(cfg.UI.Templates(), "\n"))
 	}
 
+	// One palette for every builtin: compiling the theme per segment would
+	// repeat the work on each invocation.
+	pal := palette.From(cfg)
+
 	var wg sync.WaitGroup
 	results := make(chan types.Segment, len(segmentIDs))
 
-	for _, id := range segmentIDs {
+	for _, id := range pluginIDs(segmentIDs, cfg) {
 		wg.Add(1)
 		go func(id string) {
 			defer wg.Done()
@@ -89,16 +96,19 @@ func Collect(ctx context.Context, ctxObj map[string]any, claudeJSON []byte, cfg
 			defer cancel()
 
 			var seg types.Segment
-			if pcfg.Type == "exec" && pcfg.Command != "" {
-				seg = runExec(pctx, pcfg, claudeJSON)
+			if pcfg.CacheTTLMS > 0 {
+				seg = runCached(pctx, id, pcfg, ctxObj, claudeJSON, cfg, pal)
 			} else {
-				seg = runBuiltin(pctx, id, ctxObj, cfg)
+				seg = runSegment(pctx, id, pcfg, ctxObj, claudeJSON, cfg, pal)
 			}
 
 			seg.ID = id
 			if seg.Priority == 0 {
 				seg.Priority = 50
 			}
+			if pcfg.Truncate != "" {
+				seg.Truncate = pcfg.Truncate
+			}
 			results <- seg
 		}(id)
 	}
@@ -110,35 +120,156 @@ func Collect(ctx context.Context, ctxObj map[string]any, claudeJSON []byte, cfg
 
 	var segments []types.Segment
 	for seg := range results {
+		parts := seg.Parts
+		seg.Parts = nil
 		if seg.Text != "" {
 			segments = append(segments, seg)
 		}
+		for _, part := range parts {
+			part.ID = seg.ID + ":" + part.ID
+			if part.Priority == 0 {
+				part.Priority = 50
+			}
+			if part.Truncate == "" || cfg.Plugin[seg.ID].Truncate != "" {
+				part.Truncate = seg.Truncate
+			}
+			if part.Text != "" {
+				segments = append(segments, part)
+			}
+		}
 	}
 	return segments
 }
 
-func runBuiltin(ctx context.Context, id string, raw map[string]any, cfg *config.Config) types.Segment {
+// pluginIDs maps the segment ids a template uses to the plugins that
+// produce them, each once: {kube:ns} comes from the exec or daemon plugin kube.
+func pluginIDs(segmentIDs []string, cfg *config.Config) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, id := range segmentIDs {
+		if _, ok := cfg.Plugin[id]; !ok {
+			if base, _, found := strings.Cut(id, ":"); found && (cfg.Plugin[base].Type == "exec" || cfg.Plugin[base].Type == "daemon") {
+				id = base
+			}
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func runSegment(ctx context.Context, id string, pcfg config.PluginConfig, ctxObj map[string]any, claudeJSON []byte, cfg *config.Config, pal *palette.Palette) types.Segment {
+	if pcfg.Type == "exec" && pcfg.Command != "" {
+		return runExec(ctx, pluginCmd(ctx, pcfg, execDir(pcfg, ctxObj), execVars(id, ctxObj, cfg)...), claudeJSON)
+	}
+	if pcfg.Type == "daemon" && (pcfg.Command != "" || pcfg.Socket != "") {
+		return runDaemon(ctx, id, pcfg, claudeJSON, columns(cfg))
+	}
+	return runBuiltin(ctx, id, ctxObj, cfg, pal)
+}
+
+// runCached serves a segment from the on-disk cache: fresh entries as-is,
+// stale ones while a detached process refreshes them. A miss runs inline,
+// and if that blows the timeout the refresh continues in the background so
+// the next invocation has something to show.
+func runCached(ctx context.Context, id string, pcfg config.PluginConfig, ctxObj map[string]any, claudeJSON []byte, cfg *config.Config, pal *palette.Palette) types.Segment {
+	key := cache.Key(id, pcfg.CacheKey, ctxObj)
+	if e, ok := cache.Load(key); ok {
+		if !e.Fresh(time.Duration(pcfg.CacheTTLMS) * time.Millisecond) {
+			cache.Revalidate(id, key, claudeJSON)
+		}
+		return e.Segment
+	}
+
+	seg := runSegment(ctx, id, pcfg, ctxObj, claudeJSON, cfg, pal)
+	if ctx.Err() != nil {
+		cache.Revalidate(id, key, claudeJSON)
+		return types.Segment{}
+	}
+	if cacheable(seg) {
+		_ = cache.Store(key, seg)
+	}
+	return seg
+}
+
+// errNoValue is a refresh that produced nothing to show: the plugin failed
+// or the builtin had no data. The stale entry is kept rather than replaced.
+var errNoValue = errors.New("segment produced no value")
+
+// Refresh recomputes one cached segment without the interactive time budget
+// and stores it under key. It backs the detached `ccsl __refresh` process.
+func Refresh(ctx context.Context, id, key string, ctxObj map[string]any, claudeJSON []byte, cfg *config.Config) error {
+	defer cache.Unlock(key)
+	seg := runSegment(ctx, id, cfg.Plugin[id], ctxObj, claudeJSON, cfg, palette.From(cfg))
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !cacheable(seg) {
+		return errNoValue
+	}
+	return cache.Store(key, seg)
+}
+
+// cacheable tells a segment worth keeping from the empty one a failed
+// plugin or builtin returns.
+func cacheable(seg types.Segment) bool {
+	return seg.Text != "" || len(seg.Parts) > 0
+}
+
+func runBuiltin(ctx context.Context, id string, raw map[string]any, cfg *config.Config, pal *palette.Palette) types.Segment {
 	switch id {
 	case "model":
 		return model.Parse(raw).Render()
 	case "cwd":
 		return cwd.Parse(raw).Render()
 	case "git":
-		if s, ok := git.Collect(ctx, cfg); ok {
-			return s.Render(cfg.Theme.ANSI)
+		dir := cwd.Parse(raw).Path
+		if s, ok := git.Collect(ctx, dir, cfg); ok {
+			if r, ok := forgeRepo(dir, cfg); ok {
+				branch := s.Branch
+				if r.Branch != "" {
+					branch = r.Branch // pushed under another name
+				}
+				s.URL = r.BranchURL(branch)
+			}
+			return s.Render(pal)
 		}
 	case "cost":
-		if s, ok := cost.Parse(raw); ok {
+		s, ok := cost.Parse(raw)
+		if mode := cfg.Plugin["cost"].Estimate; mode == "always" || mode != "never" && s.CostUSD == 0 {
+			if usd, found := EstimateCost(ctx, raw, cfg); found {
+				s.CostUSD, s.Estimated, ok = usd, true, true
+			}
+		}
+		if ok {
+			if cfg.Budget.Enabled() {
+				s.Budget = s.CheckBudget(cfg.Budget, spentSoFar(raw))
+			}
 			return s.Render()
 		}
 	case "ctx":
 		if c, ok := ctxbuiltin.Parse(raw); ok {
+			c.Configure(cfg.Plugin["ctx"])
+			if !cfg.DryRun {
+				sessionID, _ := raw["session_id"].(string)
+				ctxbuiltin.Track(&c, sessionID, cfg.Plugin["ctx"].Trend)
+			}
 			return c.Render()
 		}
 	case "gcp":
 		return gcp.Render(raw)
 	case "cf", "cloudflare":
 		return cloudflare.Render(raw)
+	case "aws":
+		if s, ok := aws.Collect(time.Now()); ok {
+			return s.Render(pal)
+		}
+	case "k8s":
+		if c, ok := k8s.Current(); ok {
+			return c.Render(cfg.Plugin["k8s"].Production)
+		}
 	case "agent":
 		if a, ok := agent.Parse(raw); ok {
 			return a.Render()
@@ -153,7 +284,11 @@ func runBuiltin(ctx context.Context, id string, raw map[string]any, cfg *config.
 		}
 	case "ratelimit":
 		if l, ok := ratelimit.Parse(raw); ok {
-			return l.Render(cfg.Theme.ANSI)
+			l.Configure(cfg.Plugin["ratelimit"])
+			if !cfg.DryRun {
+				ratelimit.Track(&l, time.Now(), cfg.Plugin["ratelimit"])
+			}
+			return l.Render(pal)
 		}
 	case "worktree":
 		if w, ok := worktree.Parse(raw); ok {
@@ -165,14 +300,122 @@ func runBuiltin(ctx context.Context, id string, raw map[string]any, cfg *config.
 		}
 	case "pr":
 		if p, ok := pr.Parse(raw); ok {
-			return p.Render(cfg.Theme.ANSI)
+			if r, ok := forgeRepo(cwd.Parse(raw).Path, cfg); ok && p.URL == "" {
+				p.URL = r.PullURL(p.Number)
+			}
+			return p.Render(pal)
+		}
+	case "spend":
+		if s, ok := spend.Collect(time.Now()); ok {
+			return s.Render()
+		}
+	case "tokens":
+		t, complete := tokens.Parse(raw)
+		if !complete {
+			if s, ok := transcript.Read(ctx, raw); ok {
+				t.Fill(s)
+			}
+		}
+		return t.Render(pal)
+	case "tool":
+		if s, ok := transcript.Read(ctx, raw); ok {
+			return tool.Last(s)
+		}
+	case "tools":
+		if s, ok := transcript.Read(ctx, raw); ok {
+			return tool.Count(s)
+		}
+	case "todos":
+		if s, ok := transcript.Read(ctx, raw); ok {
+			return todos.Render(s)
+		}
+	case "since":
+		if s, ok := transcript.Read(ctx, raw); ok {
+			return since.Render(s, time.Now())
 		}
 	}
 	return types.Segment{}
 }
 
-func runExec(ctx context.Context, pcfg config.PluginConfig, claudeJSON []byte) types.Segment {
-	cmd := exec.CommandContext(ctx, pcfg.Command, pcfg.Args...)
+// EstimateCost prices the session's tokens: per model from the transcript,
+// or else the statusline's totals at the current model's rates, all input
+// as uncached.
+func EstimateCost(ctx context.Context, raw map[string]any, cfg *config.Config) (float64, bool) {
+	table := pricing.Load(cfg.Pricing)
+	if s, ok := transcript.Read(ctx, raw); ok && len(s.ByModel) > 0 {
+		return table.Estimate(s.ByModel)
+	}
+	t, _ := tokens.Parse(raw)
+	if t.Input+t.Output == 0 {
+		return 0, false
+	}
+	return table.Estimate(map[string]transcript.Usage{
+		model.Parse(raw).ID: {Input: t.Input, Output: t.Output},
+	})
+}
+
+// forgeRepo finds the web home of dir's repository for git and pr links,
+// only when they will be shown.
+func forgeRepo(dir string, cfg *config.Config) (forge.Repo, bool) {
+	if !cfg.Theme.Hyperlinks {
+		return forge.Repo{}, false
+	}
+	return forge.Lookup(dir)
+}
+
+// spentSoFar totals the ledger for the [budget] day, month and project
+// limits. The current session is already recorded by the time this runs.
+func spentSoFar(raw map[string]any) cost.Spent {
+	now := time.Now()
+	sessions, err := ledger.Load(ledger.Since(now))
+	if err != nil {
+		return cost.Spent{}
+	}
+	totals := ledger.Sum(sessions, now)
+	var project string
+	if ws, ok := raw["workspace"].(map[string]any); ok {
+		project, _ = ws["project_dir"].(string)
+	}
+	return cost.Spent{
+		Day:     totals.Today,
+		Month:   totals.Month,
+		Project: ledger.ProjectDay(sessions, project, now),
+	}
+}
+
+// runDaemon asks the plugin's daemon for its segments, launching the
+// supervisor when nothing answers on the socket. A daemon still starting
+// up when the timeout hits just misses this refresh.
+//
+// The daemon is shared by every session, so it gets the configured cwd
+// only, and just CCSL_PROTOCOL; the segment id and columns come with each
+// request.
+func runDaemon(ctx context.Context, id string, pcfg config.PluginConfig, claudeJSON []byte, cols int) types.Segment {
+	params := daemon.RenderParams{Segment: id, Statusline: claudeJSON, Columns: cols}
+	var plugin *exec.Cmd
+	sock := expand(pcfg.Socket)
+	if sock == "" {
+		plugin = pluginCmd(ctx, pcfg, expand(pcfg.Cwd), "CCSL_PROTOCOL="+protocolVersion)
+		sock = daemon.SocketPath(daemonKey(plugin, pcfg)...)
+	}
+	res, err := daemon.Call(ctx, sock, params)
+	if errors.Is(err, daemon.ErrNotRunning) && plugin != nil {
+		idle := time.Duration(pcfg.IdleTimeoutMS) * time.Millisecond
+		if daemon.Launch(sock, idle, plugin) != nil {
+			return types.Segment{}
+		}
+		for errors.Is(err, daemon.ErrNotRunning) && ctx.Err() == nil {
+			time.Sleep(5 * time.Millisecond)
+			res, err = daemon.Call(ctx, sock, params)
+		}
+	}
+	if err != nil {
+		return types.Segment{}
+	}
+	return parseResult(res)
+}
+
+func runExec(ctx context.Context, cmd *exec.Cmd, claudeJSON []byte) types.Segment {
 	cmd.Stdin = bytes.NewReader(claudeJSON)
 	var buf bytes.Buffer
 	lw := &limitedWriter{w: &buf, n: maxPluginStdout}
@@ -183,17 +426,5 @@ func runExec(ctx context.Context, pcfg config.PluginConfig, claudeJSON []byte) t
 		return types.Segment{}
 	}
 
-	raw := strings.TrimSpace(buf.String())
-	if raw == "" {
-		return types.Segment{}
-	}
-	if i := strings.IndexByte(raw, '\n'); i >= 0 {
-		raw = raw[:i]
-	}
-
-	var resp types.Segment
-	if json.Unmarshal([]byte(raw), &resp) == nil && resp.Text != "" {
-		return resp
-	}
-	return types.Segment{Text: raw}
+	return parseOutput(buf.String())
 }
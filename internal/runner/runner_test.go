@@ -0,0 +1,77 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hergert/ccsl/internal/cache"
+	"github.com/hergert/ccsl/internal/config"
+	"github.com/hergert/ccsl/internal/types"
+)
+
+func TestRefreshKeepsLastGoodValue(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	script := filepath.Join(t.TempDir(), "flaky")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\ncat >/dev/null\nexit 1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		Plugin: map[string]config.PluginConfig{"flaky": {Type: "exec", Command: script, CacheTTLMS: 1000}},
+	}
+	ctxObj := map[string]any{}
+	key := cache.Key("flaky", nil, ctxObj)
+	good := types.Segment{Text: "up"}
+	if err := cache.Store(key, good); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Refresh(context.Background(), "flaky", key, ctxObj, []byte(`{}`), cfg); err == nil {
+		t.Error("Refresh of a failing plugin returned no error")
+	}
+	if e, ok := cache.Load(key); !ok || e.Segment.Text != good.Text {
+		t.Errorf("after failed refresh: Load = %+v, %v; want %q kept", e, ok, good.Text)
+	}
+
+	// A miss served inline leaves nothing behind either.
+	pcfg := cfg.Plugin["flaky"]
+	pcfg.CacheKey = []string{"session_id"}
+	if seg := runCached(context.Background(), "flaky", pcfg, ctxObj, []byte(`{}`), cfg, nil); seg.Text != "" {
+		t.Errorf("runCached = %+v, want empty", seg)
+	}
+	if _, ok := cache.Load(cache.Key("flaky", pcfg.CacheKey, ctxObj)); ok {
+		t.Error("failed inline run was cached")
+	}
+}
+
+func TestDryRunLeavesStateAlone(t *testing.T) {
+	state := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", state)
+	cfg := &config.Config{
+		UI:     config.UIConfig{Template: "{ctx} {ratelimit}"},
+		Limits: config.LimitsConfig{PerPluginTimeoutMS: 1000},
+		Plugin: map[string]config.PluginConfig{"ctx": {Trend: "delta"}},
+		DryRun: true,
+	}
+	ctxObj := map[string]any{
+		"session_id":     "s1",
+		"context_window": map[string]any{"used_percentage": 40.0, "context_window_size": 200000.0},
+		"rate_limits": map[string]any{"five_hour": map[string]any{
+			"used_percentage": 50.0, "resets_at": float64(time.Now().Add(time.Hour).Unix()),
+		}},
+	}
+	if segs := Collect(context.Background(), ctxObj, []byte(`{}`), cfg); len(segs) != 2 {
+		t.Errorf("Collect = %+v, want ctx and ratelimit", segs)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(state, "ccsl")); len(entries) != 0 {
+		t.Errorf("dry run wrote state: %v", entries)
+	}
+
+	cfg.DryRun = false
+	Collect(context.Background(), ctxObj, []byte(`{}`), cfg)
+	if entries, _ := os.ReadDir(filepath.Join(state, "ccsl")); len(entries) == 0 {
+		t.Error("Collect wrote no state; the dry run check proves nothing")
+	}
+}
@@ -0,0 +1,282 @@
+// Package transcript follows a Claude session's JSONL transcript (the
+// statusline's transcript_path) for the tool, tools, todos and since
+// builtins, and for tokens when the statusline has no counts. Each read
+// picks up at the byte offset the previous ccsl invocation stopped at, kept
+// per session in the state dir, so even a transcript of many megabytes is
+// only scanned once.
+package transcript
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hergert/ccsl/internal/xdg"
+)
+
+// Summary is what the transcript says so far about the main conversation;
+// subagent (sidechain) entries are left out.
+type Summary struct {
+	LastTool   string    `json:"last_tool,omitempty"`
+	ToolCalls  int       `json:"tool_calls"`
+	Todos      int       `json:"todos"`   // items in the latest TodoWrite
+	Pending    int       `json:"pending"` // of those, not yet completed
+	LastPrompt time.Time `json:"last_prompt"`
+	Usage      Usage     `json:"usage"`      // summed over the session
+	LastUsage  Usage     `json:"last_usage"` // of the latest API response
+
+	// Usage summed per model id, for pricing a session that switched.
+	ByModel map[string]Usage `json:"by_model,omitempty"`
+}
+
+// Usage is the token count of an API response, or a sum of them.
+type Usage struct {
+	Input      int64 `json:"input_tokens"`
+	Output     int64 `json:"output_tokens"`
+	CacheRead  int64 `json:"cache_read_input_tokens"`
+	CacheWrite int64 `json:"cache_creation_input_tokens"`
+}
+
+func (u Usage) add(v Usage, sign int64) Usage {
+	return Usage{
+		Input:      u.Input + sign*v.Input,
+		Output:     u.Output + sign*v.Output,
+		CacheRead:  u.CacheRead + sign*v.CacheRead,
+		CacheWrite: u.CacheWrite + sign*v.CacheWrite,
+	}
+}
+
+// stateVersion changes whenever Summary gains something older state
+// files would lack, so they are rebuilt from the start.
+const stateVersion = 2
+
+// state is a Summary of the transcript at path up to Offset, the end of
+// the last complete line read. LastMessage is the id of the API response
+// LastUsage came from, and LastModel its model: a response spans several
+// lines, each repeating its usage so far.
+type state struct {
+	Version     int    `json:"version"`
+	Path        string `json:"path"`
+	Offset      int64  `json:"offset"`
+	LastMessage string `json:"last_message,omitempty"`
+	LastModel   string `json:"last_model,omitempty"`
+	Summary
+}
+
+type result struct {
+	s  Summary
+	ok bool
+}
+
+// The builtins reading the transcript run in parallel; the first one reads
+// it and the others share its result.
+var (
+	mu   sync.Mutex
+	memo = make(map[string]result)
+)
+
+// Read brings the summary of the statusline's transcript up to date. It is
+// false without a transcript, or when ctx ran out before the end was
+// reached; the progress is kept, so a later invocation finishes the job.
+func Read(ctx context.Context, raw map[string]any) (Summary, bool) {
+	path, _ := raw["transcript_path"].(string)
+	if path == "" {
+		return Summary{}, false
+	}
+	sessionID, _ := raw["session_id"].(string)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if r, ok := memo[path]; ok {
+		return r.s, r.ok
+	}
+	s, ok := read(ctx, sessionID, path)
+	memo[path] = result{s, ok}
+	return s, ok
+}
+
+func statePath(sessionID string) string {
+	return filepath.Join(xdg.StateDir(), "transcript", sessionID+".json")
+}
+
+// read resumes from the session's saved state. Without a usable session
+// id it reads the whole transcript and saves nothing.
+func read(ctx context.Context, sessionID, path string) (Summary, bool) {
+	persist := sessionID != "" && !strings.ContainsAny(sessionID, `/\`)
+	var st state
+	if persist {
+		if data, err := os.ReadFile(statePath(sessionID)); err == nil {
+			_ = json.Unmarshal(data, &st)
+		} else {
+			xdg.PruneSessions(filepath.Dir(statePath(sessionID))) // a new session: clear out old ones
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return Summary{}, false
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return Summary{}, false
+	}
+	if st.Version != stateVersion || st.Path != path || info.Size() < st.Offset {
+		st = state{Version: stateVersion, Path: path} // a new or rewritten transcript
+	}
+	if _, err := f.Seek(st.Offset, io.SeekStart); err != nil {
+		return Summary{}, false
+	}
+
+	start := st.Offset
+	done := scan(ctx, bufio.NewReaderSize(f, 64*1024), &st)
+	if persist && st.Offset != start {
+		if data, err := json.Marshal(st); err == nil {
+			_ = xdg.WriteFileAtomic(statePath(sessionID), data)
+		}
+	}
+	return st.Summary, done
+}
+
+// scan applies complete lines to st until EOF, reporting whether it got
+// there. A final line without its newline is still being written and is
+// left for next time.
+func scan(ctx context.Context, r *bufio.Reader, st *state) bool {
+	for {
+		if ctx.Err() != nil {
+			return false
+		}
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			return err == io.EOF
+		}
+		st.Offset += int64(len(line))
+		st.apply(line)
+	}
+}
+
+// entry is the part of a transcript line the summary needs.
+type entry struct {
+	Type        string `json:"type"`
+	IsMeta      bool   `json:"isMeta"`
+	IsSidechain bool   `json:"isSidechain"`
+	Timestamp   string `json:"timestamp"`
+	Message     struct {
+		ID      string          `json:"id"`
+		Model   string          `json:"model"`
+		Usage   *Usage          `json:"usage"`
+		Content json.RawMessage `json:"content"`
+	} `json:"message"`
+}
+
+type block struct {
+	Type  string          `json:"type"`
+	Name  string          `json:"name"`
+	Input json.RawMessage `json:"input"`
+}
+
+// Lines that can't matter are recognized without decoding them: tool
+// results and long assistant replies make up most of a transcript's bytes.
+var (
+	markToolUse    = []byte(`"type":"tool_use"`)
+	markUsage      = []byte(`"usage":{`)
+	markUser       = []byte(`"type":"user"`)
+	markToolResult = []byte(`"type":"tool_result"`)
+)
+
+func (st *state) apply(line []byte) {
+	assistant := bytes.Contains(line, markToolUse) || bytes.Contains(line, markUsage)
+	prompt := bytes.Contains(line, markUser) && !bytes.Contains(line, markToolResult)
+	if !assistant && !prompt {
+		return
+	}
+	var e entry
+	if json.Unmarshal(line, &e) != nil || e.IsSidechain {
+		return
+	}
+	switch e.Type {
+	case "assistant":
+		if u := e.Message.Usage; u != nil {
+			st.addUsage(e.Message.ID, e.Message.Model, *u)
+		}
+		var blocks []block
+		if json.Unmarshal(e.Message.Content, &blocks) != nil {
+			return
+		}
+		for _, b := range blocks {
+			if b.Type != "tool_use" || b.Name == "" {
+				continue
+			}
+			st.ToolCalls++
+			st.LastTool = b.Name
+			if b.Name == "TodoWrite" {
+				st.todoWrite(b.Input)
+			}
+		}
+	case "user":
+		if e.IsMeta || !isPrompt(e.Message.Content) {
+			return
+		}
+		if t, err := time.Parse(time.RFC3339, e.Timestamp); err == nil {
+			st.LastPrompt = t
+		}
+	}
+}
+
+// addUsage counts u, replacing the usage counted for the same response
+// on an earlier line.
+func (st *state) addUsage(id, model string, u Usage) {
+	if st.ByModel == nil {
+		st.ByModel = make(map[string]Usage)
+	}
+	if id != "" && id == st.LastMessage {
+		st.Usage = st.Usage.add(st.LastUsage, -1)
+		st.ByModel[st.LastModel] = st.ByModel[st.LastModel].add(st.LastUsage, -1)
+	}
+	st.Usage = st.Usage.add(u, 1)
+	st.ByModel[model] = st.ByModel[model].add(u, 1)
+	st.LastUsage, st.LastMessage, st.LastModel = u, id, model
+}
+
+func (st *state) todoWrite(input json.RawMessage) {
+	var in struct {
+		Todos []struct {
+			Status string `json:"status"`
+		} `json:"todos"`
+	}
+	if json.Unmarshal(input, &in) != nil {
+		return
+	}
+	st.Todos, st.Pending = len(in.Todos), 0
+	for _, t := range in.Todos {
+		if t.Status != "completed" {
+			st.Pending++
+		}
+	}
+}
+
+// isPrompt tells what the user typed from the other user-role entries:
+// tool results, and the output of local slash commands.
+func isPrompt(content json.RawMessage) bool {
+	var text string
+	if json.Unmarshal(content, &text) == nil {
+		return text != "" && !strings.HasPrefix(text, "<local-command-")
+	}
+	var blocks []block
+	if json.Unmarshal(content, &blocks) != nil {
+		return false
+	}
+	for _, b := range blocks {
+		if b.Type == "tool_result" {
+			return false
+		}
+	}
+	return len(blocks) > 0
+}
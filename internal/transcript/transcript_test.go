@@ -0,0 +1,184 @@
+package transcript
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	prompt    = `{"type":"user","isSidechain":false,"message":{"role":"user","content":"fix the tests"},"timestamp":"2026-05-01T12:00:00.000Z"}`
+	meta      = `{"type":"user","isMeta":true,"message":{"role":"user","content":"Caveat: ..."},"timestamp":"2026-05-01T12:30:00.000Z"}`
+	cmdOutput = `{"type":"user","message":{"role":"user","content":"<local-command-stdout>ok</local-command-stdout>"},"timestamp":"2026-05-01T12:30:00.000Z"}`
+	bash      = `{"type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"Running them."},{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"go test ./..."}}]},"timestamp":"2026-05-01T12:00:05.000Z"}`
+	toolOut   = `{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"ok"}]},"timestamp":"2026-05-01T12:00:09.000Z"}`
+	todo      = `{"type":"assistant","message":{"role":"assistant","content":[{"type":"tool_use","id":"t2","name":"TodoWrite","input":{"todos":[{"content":"a","status":"completed"},{"content":"b","status":"in_progress"},{"content":"c","status":"pending"}]}}]},"timestamp":"2026-05-01T12:01:00.000Z"}`
+	subagent  = `{"type":"assistant","isSidechain":true,"message":{"role":"assistant","content":[{"type":"tool_use","id":"t3","name":"Grep","input":{}}]},"timestamp":"2026-05-01T12:02:00.000Z"}`
+	image     = `{"type":"user","message":{"role":"user","content":[{"type":"text","text":"look"},{"type":"image","source":{}}]},"timestamp":"2026-05-01T12:05:00.000Z"}`
+)
+
+func write(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(strings.Join(lines, "")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadIncremental(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "s1.jsonl")
+	write(t, path, prompt+"\n", bash+"\n", toolOut+"\n", meta+"\n", cmdOutput+"\n", subagent+"\n")
+
+	s, ok := read(context.Background(), "s1", path)
+	if !ok {
+		t.Fatal("read not ok")
+	}
+	if s.LastTool != "Bash" || s.ToolCalls != 1 || s.Todos != 0 {
+		t.Errorf("after first read: %+v", s)
+	}
+	if want := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC); !s.LastPrompt.Equal(want) {
+		t.Errorf("LastPrompt = %v, want %v", s.LastPrompt, want)
+	}
+
+	// The next line is still being written: it counts once complete.
+	write(t, path, todo+"\n", image[:40])
+	s, _ = read(context.Background(), "s1", path)
+	if s.LastTool != "TodoWrite" || s.ToolCalls != 2 || s.Todos != 3 || s.Pending != 2 {
+		t.Errorf("after TodoWrite: %+v", s)
+	}
+	write(t, path, image[40:]+"\n")
+	s, _ = read(context.Background(), "s1", path)
+	if want := time.Date(2026, 5, 1, 12, 5, 0, 0, time.UTC); !s.LastPrompt.Equal(want) || s.ToolCalls != 2 {
+		t.Errorf("after completed prompt: %+v", s)
+	}
+}
+
+func TestReadResumesFromOffset(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "s2.jsonl")
+	write(t, path, bash+"\n")
+	if s, _ := read(context.Background(), "s2", path); s.ToolCalls != 1 {
+		t.Fatalf("ToolCalls = %d, want 1", s.ToolCalls)
+	}
+
+	// Only the new lines are read; a rescan would count the first call twice.
+	write(t, path, "not json\n", bash+"\n")
+	if s, _ := read(context.Background(), "s2", path); s.ToolCalls != 2 {
+		t.Errorf("ToolCalls = %d, want 2", s.ToolCalls)
+	}
+
+	// A transcript that shrank was rewritten: start over.
+	if err := os.WriteFile(path, []byte(todo+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := read(context.Background(), "s2", path); s.ToolCalls != 1 || s.LastTool != "TodoWrite" {
+		t.Errorf("after rewrite: %+v", s)
+	}
+}
+
+func TestReadDeadline(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "s3.jsonl")
+	write(t, path, bash+"\n", bash+"\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, ok := read(ctx, "s3", path); ok {
+		t.Error("read past its deadline reported ok")
+	}
+	if s, ok := read(context.Background(), "s3", path); !ok || s.ToolCalls != 2 {
+		t.Errorf("resumed read = %+v, %v", s, ok)
+	}
+}
+
+func TestReadLarge(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "s4.jsonl")
+	big := `{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"` +
+		strings.Repeat("x", 64*1024) + `"}]}}` + "\n"
+	var lines []string
+	for i := 0; i < 100; i++ {
+		lines = append(lines, bash+"\n", big)
+	}
+	write(t, path, lines...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if s, ok := read(ctx, "s4", path); !ok || s.ToolCalls != 100 {
+		t.Errorf("read = %+v, %v", s, ok)
+	}
+}
+
+func TestReadWithoutSession(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "s5.jsonl")
+	write(t, path, bash+"\n")
+	for i := 0; i < 2; i++ {
+		if s, _ := read(context.Background(), "../escape", path); s.ToolCalls != 1 {
+			t.Errorf("read %d: ToolCalls = %d, want 1", i, s.ToolCalls)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(os.Getenv("XDG_STATE_HOME"), "ccsl", "transcript")); !os.IsNotExist(err) {
+		t.Errorf("state saved for an unusable session id: %v", err)
+	}
+}
+
+func TestReadUsage(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "s6.jsonl")
+	line := func(id, model string, in, out, read, write int) string {
+		return fmt.Sprintf(`{"type":"assistant","message":{"id":%q,"model":%q,"role":"assistant","content":[{"type":"text","text":"…"}],`+
+			`"usage":{"input_tokens":%d,"output_tokens":%d,"cache_read_input_tokens":%d,"cache_creation_input_tokens":%d}}}`+"\n",
+			id, model, in, out, read, write)
+	}
+	// One response streamed over two lines repeats its usage, growing.
+	write(t, path, line("m1", "opus", 10, 5, 1000, 200), line("m1", "opus", 10, 40, 1000, 200), line("m2", "haiku", 3, 7, 1200, 0))
+
+	s, _ := read(context.Background(), "s6", path)
+	if want := (Usage{Input: 13, Output: 47, CacheRead: 2200, CacheWrite: 200}); s.Usage != want {
+		t.Errorf("Usage = %+v, want %+v", s.Usage, want)
+	}
+	if want := (Usage{Input: 3, Output: 7, CacheRead: 1200}); s.LastUsage != want {
+		t.Errorf("LastUsage = %+v, want %+v", s.LastUsage, want)
+	}
+	if want := (Usage{Input: 10, Output: 40, CacheRead: 1000, CacheWrite: 200}); s.ByModel["opus"] != want {
+		t.Errorf("ByModel[opus] = %+v, want %+v", s.ByModel["opus"], want)
+	}
+
+	// Resumed between the lines of a response, it still counts it once.
+	write(t, path, line("m3", "opus", 1, 1, 0, 0))
+	read(context.Background(), "s6", path)
+	write(t, path, line("m3", "opus", 1, 9, 0, 0))
+	s, _ = read(context.Background(), "s6", path)
+	if s.Usage.Output != 56 || s.ByModel["opus"].Output != 49 || s.ByModel["haiku"].Output != 7 {
+		t.Errorf("Usage = %+v, ByModel = %+v", s.Usage, s.ByModel)
+	}
+}
+
+func TestReadPrunesOldState(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "s.jsonl")
+	write(t, path, bash+"\n")
+	read(context.Background(), "old", path)
+	long := time.Now().Add(-30 * 24 * time.Hour)
+	if err := os.Chtimes(statePath("old"), long, long); err != nil {
+		t.Fatal(err)
+	}
+
+	read(context.Background(), "new", path)
+	if _, err := os.Stat(statePath("old")); !os.IsNotExist(err) {
+		t.Errorf("stale state kept: %v", err)
+	}
+	if _, err := os.Stat(statePath("new")); err != nil {
+		t.Errorf("new state: %v", err)
+	}
+}
@@ -3,6 +3,13 @@ package types
 type Segment struct {
 	ID       string `json:"id"`
 	Text     string `json:"text"`
-	Style    string `json:"style"`    // "normal" | "bold" | "dim"
-	Priority int    `json:"priority"` // for truncation, default 50
+	Short    string `json:"short_text,omitempty"` // compact form tried before ellipsizing
+	Long     string `json:"long_text,omitempty"`  // fuller form, picked with form=long
+	Style    string `json:"style"`                // "normal" | "bold" | "dim"
+	Priority int    `json:"priority"`             // for truncation, default 50
+	Truncate string `json:"truncate,omitempty"`   // "drop" (default) | "shorten" | "never"
+	Link     string `json:"link,omitempty"`       // URL, clickable with [theme] hyperlinks
+
+	// Sub-segments of an exec plugin, addressed as {plugin:id}.
+	Parts []Segment `json:"parts,omitempty"`
 }
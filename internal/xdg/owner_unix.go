@@ -0,0 +1,13 @@
+//go:build unix
+
+package xdg
+
+import (
+	"os"
+	"syscall"
+)
+
+func ownedByMe(fi os.FileInfo) bool {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	return ok && int(st.Uid) == os.Getuid()
+}
@@ -0,0 +1,109 @@
+// Package xdg locates ccsl's cache, state and runtime directories and
+// writes files there safely for concurrent Claude sessions.
+package xdg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// CacheDir is $XDG_CACHE_HOME/ccsl, defaulting to ~/.cache/ccsl.
+func CacheDir() string {
+	return dir("XDG_CACHE_HOME", ".cache")
+}
+
+// StateDir is $XDG_STATE_HOME/ccsl, defaulting to ~/.local/state/ccsl.
+func StateDir() string {
+	return dir("XDG_STATE_HOME", filepath.Join(".local", "state"))
+}
+
+// RuntimeDir is $XDG_RUNTIME_DIR/ccsl, for sockets. Without one it is a
+// per-user directory under the system temp dir: socket paths must stay
+// short, which a home directory doesn't guarantee. That path is
+// predictable, so create and check it with PrivateDir before use.
+func RuntimeDir() string {
+	if base := os.Getenv("XDG_RUNTIME_DIR"); base != "" {
+		return filepath.Join(base, "ccsl")
+	}
+	return filepath.Join(os.TempDir(), "ccsl-"+strconv.Itoa(os.Getuid()))
+}
+
+// ErrNotPrivate is a directory that another user could have planted or
+// can write to.
+var ErrNotPrivate = errors.New("directory is not private to this user")
+
+// PrivateDir creates dir if it is missing and checks it with CheckPrivate.
+// MkdirAll alone accepts a directory someone else made first.
+func PrivateDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	return CheckPrivate(dir)
+}
+
+// CheckPrivate fails unless dir is a real directory, not a symlink, owned
+// by the current user with mode 0700.
+func CheckPrivate(dir string) error {
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() || fi.Mode().Perm() != 0o700 || !ownedByMe(fi) {
+		return fmt.Errorf("%w: %s (%v)", ErrNotPrivate, dir, fi.Mode())
+	}
+	return nil
+}
+
+func dir(env, fallback string) string {
+	base := os.Getenv(env)
+	if base == "" {
+		base = filepath.Join(os.Getenv("HOME"), fallback)
+	}
+	return filepath.Join(base, "ccsl")
+}
+
+// WriteFileAtomic writes data to a temp file beside path and renames it in,
+// so readers see the old or the new content, never a partial file.
+func WriteFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Per-session state is only useful while the session runs (or is resumed
+// soon after); losing it costs a rescan or a missing trend, nothing more.
+const sessionStateAge = 3 * 24 * time.Hour
+
+// PruneSessions removes the files in dir not written for a few days. Call it
+// when a new session first writes there, so the directory doesn't grow by a
+// file per session forever. Best-effort, like the state itself.
+func PruneSessions(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-sessionStateAge)
+	for _, e := range entries {
+		if fi, err := e.Info(); err == nil && fi.Mode().IsRegular() && fi.ModTime().Before(cutoff) {
+			_ = os.Remove(filepath.Join(dir, e.Name()))
+		}
+	}
+}